@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/unxai/geonames-service/api/respond"
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+type requestInfoKey struct{}
+
+// requestInfo 由内层的路由中间件填写，外层的访问日志读取
+type requestInfo struct {
+	route  string
+	fields []zap.Field // 追踪字段（trace_id、span_id）
+}
+
+// Logger 返回请求级日志（已带有请求ID），context 中没有时返回 fallback
+func Logger(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return log
+	}
+	return fallback
+}
+
+// AccessLog 为每个请求创建请求级日志并写入 context，请求结束后记录一条访问日志：
+// 方法、路由模板、状态码、响应字节数和耗时。5xx 记为 Error，其余记为 Info。
+// 记录日志后依次调用 observers
+func AccessLog(log *zap.Logger, observers ...Observer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqLog := log.With(zap.String("request_id", respond.RequestID(r)))
+			info := &requestInfo{}
+
+			ctx := context.WithValue(r.Context(), loggerKey{}, reqLog)
+			ctx = context.WithValue(ctx, requestInfoKey{}, info)
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r.WithContext(ctx))
+			latency := time.Since(start)
+
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("route", info.route),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rw.Status()),
+				zap.Int64("bytes", rw.bytes),
+				zap.Duration("latency", latency),
+				zap.String("remote_addr", r.RemoteAddr),
+			}
+			fields = append(fields, info.fields...)
+			if rw.Status() >= http.StatusInternalServerError {
+				reqLog.Error("HTTP请求", fields...)
+			} else {
+				reqLog.Info("HTTP请求", fields...)
+			}
+
+			for _, observe := range observers {
+				observe(r, info.route, rw.Status(), rw.bytes, latency)
+			}
+		})
+	}
+}
+
+// responseWriter 记录状态码和写出的字节数
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Status 返回已写出的状态码，处理器未写出任何内容时为 200
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap 供 http.ResponseController 访问底层的 ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
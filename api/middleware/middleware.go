@@ -0,0 +1,55 @@
+// Package middleware 提供所有 HTTP 接口共用的中间件：请求ID、访问日志和 panic 恢复。
+// 处理器通过 Logger 获取带有请求ID（启用追踪时还有 trace_id）的请求级日志。
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/unxai/geonames-service/tracing"
+	"go.uber.org/zap"
+)
+
+// Observer 在每个请求结束后调用，用于统计指标。route 为路由模板，未匹配任何路由时为空
+type Observer func(r *http.Request, route string, status int, bytes int64, latency time.Duration)
+
+// Wrap 按顺序为 h 套上请求ID、访问日志和 panic 恢复中间件。
+// 中间件包在路由外层，未匹配的路径同样会记录访问日志；
+// 路由模板需要在路由上注册 RouteTemplate 才能写入日志。
+func Wrap(h http.Handler, log *zap.Logger, observers ...Observer) http.Handler {
+	return RequestID(AccessLog(log, observers...)(Recover(h)))
+}
+
+// RouteTemplate 记录匹配到的路由模板（如 /v1/locations/{countryCode}），供访问日志使用。
+// 以 mux 中间件的形式注册，只在路由匹配成功后执行
+func RouteTemplate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				info.route, _ = route.GetPathTemplate()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TraceLog 将当前 span 的 trace_id 和 span_id 加入请求级日志和访问日志，
+// 以 mux 中间件的形式注册在 otelmux 之后
+func TraceLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields := tracing.LogFields(r.Context())
+		if len(fields) > 0 {
+			ctx := r.Context()
+			if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+				info.fields = fields
+			}
+			if log, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+				ctx = context.WithValue(ctx, loggerKey{}, log.With(fields...))
+			}
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
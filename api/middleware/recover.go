@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/unxai/geonames-service/api/respond"
+	"go.uber.org/zap"
+)
+
+// Recover 捕获处理器中的 panic，记录错误和调用栈并返回 JSON 格式的 500，
+// 避免连接被直接断开且没有任何日志
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw, ok := w.(*responseWriter)
+		if !ok {
+			rw = &responseWriter{ResponseWriter: w}
+		}
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// ErrAbortHandler 用于主动中断响应，交给 net/http 处理
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			Logger(r.Context(), zap.L()).Error("处理请求时发生panic",
+				zap.Any("panic", p),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Stack("stacktrace"),
+			)
+
+			// 已经开始写响应时无法再改状态码，只能中断连接
+			if rw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			respond.WriteError(rw, respond.RequestID(r), http.StatusInternalServerError,
+				respond.Error{Code: respond.CodeInternal, Message: "服务器内部错误"})
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
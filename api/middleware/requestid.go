@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/unxai/geonames-service/api/respond"
+)
+
+// maxRequestIDLength 客户端传入的请求ID的最大长度，超过时重新生成
+const maxRequestIDLength = 128
+
+// RequestID 沿用请求头 X-Request-ID 中的请求ID（便于跨服务追踪），没有或格式不合法时生成一个。
+// 请求ID写入 context 和响应头
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(respond.RequestIDHeader)
+		if !validRequestID(id) {
+			id = respond.NewRequestID()
+		}
+		w.Header().Set(respond.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(respond.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID 只接受字母、数字和 -_.: 组成的ID，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/unxai/geonames-service/api/respond"
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPISpec 手工维护的 OpenAPI 3 文档。修改接口时须同步更新，
+// openapi_test.go 会用它校验各处理器的真实响应。
+//
+//go:embed openapi.yaml
+var OpenAPISpec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+var openAPIJSON = sync.OnceValues(func() ([]byte, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(OpenAPISpec, &doc); err != nil {
+		return nil, fmt.Errorf("解析OpenAPI文档失败: %w", err)
+	}
+	return json.Marshal(doc)
+})
+
+// openAPIHandler 以 JSON 格式提供 OpenAPI 文档
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	spec, err := openAPIJSON()
+	if err != nil {
+		respond.WriteError(w, respond.RequestID(r), http.StatusInternalServerError,
+			respond.Error{Code: respond.CodeInternal, Message: "服务器内部错误"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(spec)
+}
+
+// docsHandler 提供接口文档页面。页面不依赖外部 CDN，离线部署时同样可用
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
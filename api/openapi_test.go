@@ -0,0 +1,323 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+	v1 "github.com/unxai/geonames-service/api/v1"
+	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/storage"
+	"go.uber.org/zap"
+)
+
+// fakeStorage 内存中的只读存储，err 不为空时所有查询返回该错误
+type fakeStorage struct {
+	locations []models.Location
+	history   map[int64][]models.LocationHistory
+	err       error
+}
+
+func (s *fakeStorage) StartImport(ctx context.Context, fullSync bool) (int64, error) {
+	return 0, errors.New("只读")
+}
+
+func (s *fakeStorage) SaveLocations(ctx context.Context, importID int64, locations []models.Location) error {
+	return errors.New("只读")
+}
+
+func (s *fakeStorage) DeleteStaleLocations(ctx context.Context, importID int64, maxDelete int) (int64, error) {
+	return 0, errors.New("只读")
+}
+
+func (s *fakeStorage) FinishImport(ctx context.Context, importID int64, written, deleted int64) error {
+	return errors.New("只读")
+}
+
+func (s *fakeStorage) IterateLocations(ctx context.Context, fn func(models.Location) error) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, loc := range s.locations {
+		if err := fn(loc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeStorage) ListLocations(ctx context.Context, query storage.ListQuery) ([]models.Location, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var result []models.Location
+	for _, loc := range s.locations {
+		if int64(loc.GeonameID) <= query.AfterID || (query.CountryCode != "" && loc.CountryCode != query.CountryCode) {
+			continue
+		}
+		if len(result) == query.Limit {
+			break
+		}
+		result = append(result, loc)
+	}
+	return result, nil
+}
+
+func (s *fakeStorage) CountLocations(ctx context.Context, countryCode string) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	var n int64
+	for _, loc := range s.locations {
+		if countryCode == "" || loc.CountryCode == countryCode {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *fakeStorage) NearbyLocations(ctx context.Context, lat, lon, radius float64, limit int) ([]models.LocationDistance, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var result []models.LocationDistance
+	for _, loc := range s.locations {
+		if len(result) == limit {
+			break
+		}
+		result = append(result, models.LocationDistance{Location: loc, Distance: 1234.5})
+	}
+	return result, nil
+}
+
+func (s *fakeStorage) SearchLocations(ctx context.Context, query string, fuzzy bool, limit int) ([]models.LocationMatch, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	var result []models.LocationMatch
+	for _, loc := range s.locations {
+		if len(result) == limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(loc.Name), strings.ToLower(query)) {
+			result = append(result, models.LocationMatch{Location: loc, Score: 0.8})
+		}
+	}
+	return result, nil
+}
+
+func (s *fakeStorage) GetLocationHistory(ctx context.Context, geonameID int64) ([]models.LocationHistory, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.history[geonameID], nil
+}
+
+func newFakeStorage() *fakeStorage {
+	importID := int64(1)
+	return &fakeStorage{
+		locations: []models.Location{
+			{GeonameID: 1796236, Name: "Shanghai", ASCII_Name: "Shanghai", AlternateNames: "上海,Shanghai Shi",
+				Latitude: 31.22222, Longitude: 121.45806, FeatureClass: "P", FeatureCode: "PPLA",
+				CountryCode: "CN", Admin1Code: "23", Population: 24874500, Elevation: 4,
+				TimeZone: "Asia/Shanghai", ModificationDate: "2023-01-01"},
+			{GeonameID: 1816670, Name: "Beijing", ASCII_Name: "Beijing", AlternateNames: "北京,Peking",
+				Latitude: 39.9075, Longitude: 116.39723, FeatureClass: "P", FeatureCode: "PPLC",
+				CountryCode: "CN", Admin1Code: "22", Population: 18960744, Elevation: 49,
+				TimeZone: "Asia/Shanghai", ModificationDate: "2024-02-10"},
+			{GeonameID: 2867714, Name: "München", ASCII_Name: "Muenchen", AlternateNames: "Munich",
+				Latitude: 48.13743, Longitude: 11.57549, FeatureClass: "P", FeatureCode: "PPLA",
+				CountryCode: "DE", Admin1Code: "02", Admin2Code: "091", Population: 1260391, Elevation: 524,
+				TimeZone: "Europe/Berlin", ModificationDate: "2023-10-12"},
+		},
+		history: map[int64][]models.LocationHistory{
+			1796236: {
+				{ImportID: &importID, Operation: "insert",
+					Changes:   json.RawMessage(`{"name":{"old":null,"new":"Shanghai"},"population":{"old":null,"new":24874500}}`),
+					ChangedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{ImportID: nil, Operation: "update",
+					Changes:   json.RawMessage(`{"population":{"old":24874500,"new":24874501}}`),
+					ChangedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+}
+
+// loadSpec 加载并校验 OpenAPI 文档本身
+func loadSpec(t *testing.T) (*openapi3.T, routers.Router) {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromData(OpenAPISpec)
+	if err != nil {
+		t.Fatalf("加载OpenAPI文档失败: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("OpenAPI文档无效: %v", err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("创建OpenAPI路由失败: %v", err)
+	}
+	return doc, router
+}
+
+func newTestRouter(s storage.Storage) *mux.Router {
+	return newTestRouterWithTimeouts(s, v1.Timeouts{})
+}
+
+func newTestRouterWithTimeouts(s storage.Storage, timeouts v1.Timeouts) *mux.Router {
+	r := mux.NewRouter()
+	RegisterRoutes(r, s, zap.NewNop(), timeouts)
+	return r
+}
+
+// TestResponsesMatchSpec 通过真实路由和处理器发出请求，校验状态码在文档中有声明且响应体符合 schema
+func TestResponsesMatchSpec(t *testing.T) {
+	_, specRouter := loadSpec(t)
+
+	cursor := func(s *fakeStorage) string {
+		// 取第一页的 next_cursor，用于校验翻页请求
+		rec := httptest.NewRecorder()
+		newTestRouter(s).ServeHTTP(rec, httptest.NewRequest("GET", "/v1/locations?limit=1", nil))
+		var body struct {
+			Meta struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"meta"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return body.Meta.NextCursor
+	}(newFakeStorage())
+
+	tests := []struct {
+		name    string
+		url     string
+		storage *fakeStorage
+		status  int
+	}{
+		{"列表", "/v1/locations", nil, http.StatusOK},
+		{"列表分页并统计总数", "/v1/locations?limit=1&total=true", nil, http.StatusOK},
+		{"列表第二页", "/v1/locations?limit=1&cursor=" + cursor, nil, http.StatusOK},
+		{"列表为空", "/v1/locations", &fakeStorage{}, http.StatusOK},
+		{"列表limit无效", "/v1/locations?limit=0", nil, http.StatusBadRequest},
+		{"列表cursor无效", "/v1/locations?cursor=%21%21", nil, http.StatusBadRequest},
+		{"列表total无效", "/v1/locations?total=maybe", nil, http.StatusBadRequest},
+		{"按国家查询", "/v1/locations/CN?limit=1&total=true", nil, http.StatusOK},
+		{"按国家查询无结果", "/v1/locations/FR", nil, http.StatusOK},
+		{"搜索", "/v1/locations/search?q=bei", nil, http.StatusOK},
+		{"模糊搜索", "/v1/locations/search?q=munchen&mode=fuzzy&limit=5", nil, http.StatusOK},
+		{"搜索缺少q", "/v1/locations/search", nil, http.StatusBadRequest},
+		{"搜索mode无效", "/v1/locations/search?q=x&mode=regex", nil, http.StatusBadRequest},
+		{"附近", "/v1/locations/nearby?lat=31.2&lon=121.4&radius=5000", nil, http.StatusOK},
+		{"附近缺少lat", "/v1/locations/nearby?lon=121.4", nil, http.StatusBadRequest},
+		{"附近radius超限", "/v1/locations/nearby?lat=1&lon=1&radius=1e9", nil, http.StatusBadRequest},
+		{"变更历史", "/v1/locations/id/1796236/history", nil, http.StatusOK},
+		{"变更历史不存在", "/v1/locations/id/42/history", nil, http.StatusNotFound},
+		{"数据库不可用", "/v1/locations", &fakeStorage{err: storage.ErrUnavailable}, http.StatusServiceUnavailable},
+		{"查询参数无效", "/v1/locations/search?q=x", &fakeStorage{err: storage.ErrInvalidInput}, http.StatusBadRequest},
+		{"内部错误", "/v1/locations/nearby?lat=1&lon=1", &fakeStorage{err: errors.New("boom")}, http.StatusInternalServerError},
+		{"查询超时", "/v1/locations/id/1/history", &fakeStorage{err: context.DeadlineExceeded}, http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.storage
+			if s == nil {
+				s = newFakeStorage()
+			}
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rec := httptest.NewRecorder()
+			newTestRouter(s).ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("状态码 = %d，期望 %d，响应: %s", rec.Code, tt.status, rec.Body)
+			}
+			validateResponse(t, specRouter, req, rec)
+		})
+	}
+}
+
+// slowStorage 列表查询一直阻塞到 context 结束，模拟慢查询
+type slowStorage struct {
+	*fakeStorage
+}
+
+func (s slowStorage) ListLocations(ctx context.Context, query storage.ListQuery) ([]models.Location, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestQueryTimeout 超过接口的查询超时后中断查询并返回 504
+func TestQueryTimeout(t *testing.T) {
+	_, specRouter := loadSpec(t)
+	router := newTestRouterWithTimeouts(slowStorage{newFakeStorage()}, v1.Timeouts{List: 10 * time.Millisecond})
+
+	req := httptest.NewRequest("GET", "/v1/locations/CN", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("状态码 = %d，期望 %d，响应: %s", rec.Code, http.StatusGatewayTimeout, rec.Body)
+	}
+	validateResponse(t, specRouter, req, rec)
+}
+
+func validateResponse(t *testing.T, specRouter routers.Router, req *http.Request, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	route, pathParams, err := specRouter.FindRoute(req)
+	if err != nil {
+		t.Fatalf("文档中没有 %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: rec.Code,
+		Header: rec.Header(),
+		Body:   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			MultiError:            true,
+		},
+	}
+	if err := openapi3filter.ValidateResponse(context.Background(), input); err != nil {
+		t.Fatalf("响应不符合文档: %v\n响应: %s", err, rec.Body)
+	}
+}
+
+// TestServeOpenAPI 校验 /openapi.json 与嵌入的 YAML 文档内容一致，且文档页可以访问
+func TestServeOpenAPI(t *testing.T) {
+	router := newTestRouter(newFakeStorage())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json 状态码 = %d", rec.Code)
+	}
+	served, err := openapi3.NewLoader().LoadFromData(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("解析 /openapi.json 失败: %v", err)
+	}
+	doc, _ := loadSpec(t)
+	if served.Paths.Len() != doc.Paths.Len() || len(served.Components.Schemas) != len(doc.Components.Schemas) {
+		t.Errorf("/openapi.json 与 openapi.yaml 不一致")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/docs", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("GET /docs 状态码 = %d，Content-Type = %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,128 @@
+package respond
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/unxai/geonames-service/storage"
+	"go.uber.org/zap"
+)
+
+// 错误码，客户端应据此判断错误类型，message 仅供人阅读
+const (
+	CodeInvalidArgument  = "invalid_argument"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeUnavailable      = "unavailable"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal"
+)
+
+// RequestIDHeader 请求ID的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// Error 统一的错误响应体，所有 API 版本共用
+type Error struct {
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	RequestID string            `json:"request_id,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// ErrorResponse 错误响应的外层结构：{"error": {...}}
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// WriteError 写出错误响应，请求ID同时写入响应头，便于客户端反馈问题时与日志对应
+func WriteError(w http.ResponseWriter, id string, status int, e Error) {
+	e.RequestID = id
+	w.Header().Set(RequestIDHeader, id)
+	JSON(w, status, ErrorResponse{Error: e})
+}
+
+// BadRequest 参数校验失败，details 中标明出错的参数
+func BadRequest(w http.ResponseWriter, r *http.Request, param, message string) {
+	WriteError(w, RequestID(r), http.StatusBadRequest, Error{
+		Code:    CodeInvalidArgument,
+		Message: message,
+		Details: map[string]string{"param": param},
+	})
+}
+
+// NotFound 请求的资源不存在
+func NotFound(w http.ResponseWriter, r *http.Request, message string) {
+	WriteError(w, RequestID(r), http.StatusNotFound, Error{Code: CodeNotFound, Message: message})
+}
+
+// MethodNotAllowed 路径存在但不支持该请求方法
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, RequestID(r), http.StatusMethodNotAllowed, Error{Code: CodeMethodNotAllowed, Message: "不支持的请求方法"})
+}
+
+// StorageError 将存储层错误映射为 400/404/503/504，其余作为内部错误返回 500。
+// 查询超时返回 504；请求被取消（通常是客户端已断开）返回 503，只记为 Warn。
+// 原始错误只写入日志，不返回给客户端，避免泄露数据库细节。log 应为请求级日志，已带有请求ID。
+func StorageError(w http.ResponseWriter, r *http.Request, log *zap.Logger, msg string, err error, fields ...zap.Field) {
+	id := RequestID(r)
+	status, e := http.StatusInternalServerError, Error{Code: CodeInternal, Message: "服务器内部错误"}
+
+	// 驱动中断查询时返回的错误不一定能区分超时和取消，以请求 context 的状态为准
+	kind := storage.Classify(err)
+	switch r.Context().Err() {
+	case context.DeadlineExceeded:
+		kind = storage.ErrTimeout
+	case context.Canceled:
+		kind = storage.ErrCanceled
+	}
+
+	switch {
+	case errors.Is(kind, storage.ErrInvalidInput):
+		status, e = http.StatusBadRequest, Error{Code: CodeInvalidArgument, Message: "查询参数无效"}
+	case errors.Is(kind, storage.ErrNotFound):
+		status, e = http.StatusNotFound, Error{Code: CodeNotFound, Message: "记录不存在"}
+	case errors.Is(kind, storage.ErrUnavailable):
+		status, e = http.StatusServiceUnavailable, Error{Code: CodeUnavailable, Message: "数据库暂不可用，请稍后重试"}
+	case errors.Is(kind, storage.ErrTimeout):
+		status, e = http.StatusGatewayTimeout, Error{Code: CodeTimeout, Message: "查询超时，请缩小查询范围或稍后重试"}
+	case errors.Is(kind, storage.ErrCanceled):
+		status, e = http.StatusServiceUnavailable, Error{Code: CodeUnavailable, Message: "请求已取消"}
+	}
+
+	fields = append(fields, zap.Int("status", status), zap.Error(err))
+	if status >= http.StatusInternalServerError && !errors.Is(kind, storage.ErrCanceled) {
+		log.Error(msg, fields...)
+	} else {
+		log.Warn(msg, fields...)
+	}
+
+	WriteError(w, id, status, e)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 返回携带请求ID的 context，由请求ID中间件调用
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回当前请求的ID：优先使用中间件写入 context 的值，其次是请求头，都没有时生成一个
+func RequestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	return NewRequestID()
+}
+
+// NewRequestID 生成随机的请求ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,62 @@
+package respond
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Limit 解析 limit 参数，未提供时返回 def；无效时写出 400 并返回 false
+func Limit(w http.ResponseWriter, r *http.Request, def, max int) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 || limit > max {
+		BadRequest(w, r, "limit", fmt.Sprintf("limit 必须是1到%d之间的整数", max))
+		return 0, false
+	}
+	return limit, true
+}
+
+// Bool 解析布尔参数，未提供时为 false；无效时写出 400 并返回 false
+func Bool(w http.ResponseWriter, r *http.Request, param string) (value, ok bool) {
+	v := r.URL.Query().Get(param)
+	if v == "" {
+		return false, true
+	}
+	value, err := strconv.ParseBool(v)
+	if err != nil {
+		BadRequest(w, r, param, param+" 必须是 true 或 false")
+		return false, false
+	}
+	return value, true
+}
+
+// ErrInvalidCursor 游标格式错误
+var ErrInvalidCursor = errors.New("无效的游标")
+
+// EncodeCursor 将最后一条记录的 geoname_id 编码为不透明的游标，客户端不应解析其内容
+func EncodeCursor(lastID int64) *string {
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastID, 10)))
+	return &cursor
+}
+
+// DecodeCursor 解析游标，空字符串表示第一页
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
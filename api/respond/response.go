@@ -0,0 +1,37 @@
+// Package respond 提供各版本 API 共用的响应写出、错误映射和查询参数解析。
+// 各版本可以定义自己的响应结构，但错误格式和分页游标保持一致。
+package respond
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// JSON 以 application/json 写出响应体
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ListResponse 列表接口的统一响应结构，data 在没有结果时为 [] 而不是 null
+type ListResponse[T any] struct {
+	Data []T  `json:"data"`
+	Meta Meta `json:"meta"`
+}
+
+// Meta 列表的元信息。字段始终存在，不适用时为 null
+type Meta struct {
+	Count      int     `json:"count"`       // 本页条数
+	Total      *int64  `json:"total"`       // 总条数，仅在请求 total=true 时统计
+	NextCursor *string `json:"next_cursor"` // 下一页的游标，没有更多数据时为 null
+}
+
+// List 写出列表响应
+func List[T any](w http.ResponseWriter, data []T, meta Meta) {
+	if data == nil {
+		data = []T{}
+	}
+	meta.Count = len(data)
+	JSON(w, http.StatusOK, ListResponse[T]{Data: data, Meta: meta})
+}
@@ -1,14 +1,59 @@
 package api
 
 import (
+	"net/http"
+	"strconv"
+	"time"
+
 	"github.com/gorilla/mux"
+	"github.com/unxai/geonames-service/api/respond"
+	v1 "github.com/unxai/geonames-service/api/v1"
+	"github.com/unxai/geonames-service/storage"
+	"go.uber.org/zap"
+)
+
+// 未带版本前缀的旧路径自 legacyDeprecatedAt 起弃用，legacySunsetAt 之后移除
+var (
+	legacyDeprecatedAt = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	legacySunsetAt     = time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC)
 )
 
-// RegisterRoutes 注册所有路由
-func RegisterRoutes(r *mux.Router) {
-	// 获取地理位置信息
-	r.HandleFunc("/locations", GetLocationsHandler).Methods("GET")
+// RegisterRoutes 注册所有路由。每个版本注册在各自的子路由上，新增版本时在此添加一行；
+// 旧的无版本路径作为 v1 的别名保留，响应中附带弃用信息。
+//
+// 子路由不使用 PathPrefix：mux 会把前缀匹配器复制到子路由的每条路由上，
+// 导致方法不匹配时返回 404 而不是 405，因此前缀直接拼接在各版本的路由路径中。
+func RegisterRoutes(r *mux.Router, storage storage.Storage, log *zap.Logger, timeouts v1.Timeouts) {
+	// 未匹配的路径和方法同样返回JSON错误
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond.NotFound(w, r, "接口不存在")
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(respond.MethodNotAllowed)
+
+	// 接口文档，不属于任何版本
+	r.HandleFunc("/openapi.json", openAPIHandler).Methods("GET")
+	r.HandleFunc("/docs", docsHandler).Methods("GET")
+
+	handlerV1 := v1.NewHandler(storage, log, timeouts)
+	handlerV1.Register(r.NewRoute().Subrouter(), v1.Prefix)
+
+	legacy := r.NewRoute().Subrouter()
+	legacy.Use(deprecated(v1.Prefix))
+	handlerV1.Register(legacy, "")
+}
+
+// deprecated 为旧路径的响应添加 Deprecation（RFC 9745）、Sunset（RFC 8594）头，
+// 并通过 Link 指向加上 successor 前缀的新路径
+func deprecated(successor string) mux.MiddlewareFunc {
+	deprecation := "@" + strconv.FormatInt(legacyDeprecatedAt.Unix(), 10)
+	sunset := legacySunsetAt.Format(http.TimeFormat)
 
-	// 按国家代码搜索
-	r.HandleFunc("/locations/{countryCode}", GetLocationsByCountryHandler).Methods("GET")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			w.Header().Set("Sunset", sunset)
+			w.Header().Add("Link", "<"+successor+r.URL.EscapedPath()+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
 }
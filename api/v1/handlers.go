@@ -0,0 +1,215 @@
+// Package v1 实现 /v1 版本的 HTTP 接口。
+// 响应结构一经发布即保持兼容，不兼容的调整应放到新的版本包中。
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/unxai/geonames-service/api/middleware"
+	"github.com/unxai/geonames-service/api/respond"
+	"github.com/unxai/geonames-service/storage"
+	"go.uber.org/zap"
+)
+
+// Handler 提供 v1 版本的地理位置数据接口，依赖通过 NewHandler 显式注入
+type Handler struct {
+	storage  storage.Storage
+	log      *zap.Logger
+	timeouts Timeouts
+}
+
+// Timeouts 各接口数据库查询的超时，0 表示不限制
+type Timeouts struct {
+	List    time.Duration // 列表和按国家查询，包括 total 统计
+	Search  time.Duration
+	Nearby  time.Duration
+	History time.Duration
+}
+
+// NewHandler 创建 HTTP 处理器
+func NewHandler(storage storage.Storage, log *zap.Logger, timeouts Timeouts) *Handler {
+	return &Handler{storage: storage, log: log, timeouts: timeouts}
+}
+
+// logger 返回请求级日志，未经过中间件时使用 h.log
+func (h *Handler) logger(r *http.Request) *zap.Logger {
+	return middleware.Logger(r.Context(), h.log)
+}
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// GetLocationsHandler 获取地理位置信息，按 geoname_id 升序分页
+func (h *Handler) GetLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	h.listLocations(w, r, "")
+}
+
+// GetLocationsByCountryHandler 按国家代码搜索，按 geoname_id 升序分页
+func (h *Handler) GetLocationsByCountryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	h.listLocations(w, r, vars["countryCode"])
+}
+
+// listLocations 分页查询位置数据。参数 limit 为每页条数，cursor 为上一页返回的 next_cursor，
+// total=true 时额外统计总条数
+func (h *Handler) listLocations(w http.ResponseWriter, r *http.Request, countryCode string) {
+	limit, ok := respond.Limit(w, r, defaultListLimit, maxListLimit)
+	if !ok {
+		return
+	}
+	afterID, err := respond.DecodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		respond.BadRequest(w, r, "cursor", "cursor 无效，请使用上一页返回的 next_cursor")
+		return
+	}
+	withTotal, ok := respond.Bool(w, r, "total")
+	if !ok {
+		return
+	}
+
+	// 多取一条用于判断是否还有下一页
+	locations, err := h.storage.ListLocations(r.Context(), storage.ListQuery{
+		CountryCode: countryCode,
+		AfterID:     afterID,
+		Limit:       limit + 1,
+	})
+	if err != nil {
+		respond.StorageError(w, r, h.logger(r), "查询位置数据失败", err, zap.String("country_code", countryCode))
+		return
+	}
+
+	var meta respond.Meta
+	if len(locations) > limit {
+		locations = locations[:limit]
+		meta.NextCursor = respond.EncodeCursor(int64(locations[limit-1].GeonameID))
+	}
+
+	if withTotal {
+		total, err := h.storage.CountLocations(r.Context(), countryCode)
+		if err != nil {
+			respond.StorageError(w, r, h.logger(r), "统计位置数据失败", err, zap.String("country_code", countryCode))
+			return
+		}
+		meta.Total = &total
+	}
+
+	respond.List(w, locations, meta)
+}
+
+const (
+	defaultNearbyRadius = 10000.0 // 默认搜索半径（米）
+	maxNearbyRadius     = 500000.0
+	defaultNearbyLimit  = 20
+	maxNearbyLimit      = 100
+)
+
+// GetNearbyLocationsHandler 按坐标查询附近地点，参数 lat、lon 必填，radius（米）和 limit 可选
+func (h *Handler) GetNearbyLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		respond.BadRequest(w, r, "lat", "lat 必须是-90到90之间的数字")
+		return
+	}
+	lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		respond.BadRequest(w, r, "lon", "lon 必须是-180到180之间的数字")
+		return
+	}
+
+	radius := defaultNearbyRadius
+	if v := query.Get("radius"); v != "" {
+		radius, err = strconv.ParseFloat(v, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadius {
+			respond.BadRequest(w, r, "radius", fmt.Sprintf("radius 必须是0到%g之间的数字（米）", maxNearbyRadius))
+			return
+		}
+	}
+
+	limit, ok := respond.Limit(w, r, defaultNearbyLimit, maxNearbyLimit)
+	if !ok {
+		return
+	}
+
+	locations, err := h.storage.NearbyLocations(r.Context(), lat, lon, radius, limit)
+	if err != nil {
+		respond.StorageError(w, r, h.logger(r), "查询附近地点失败", err,
+			zap.Float64("lat", lat),
+			zap.Float64("lon", lon),
+			zap.Float64("radius", radius),
+		)
+		return
+	}
+
+	respond.List(w, locations, respond.Meta{})
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// GetSearchLocationsHandler 按名称搜索地点，参数 q 必填，mode=fuzzy 时使用模糊匹配
+func (h *Handler) GetSearchLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		respond.BadRequest(w, r, "q", "缺少搜索关键词 q")
+		return
+	}
+
+	var fuzzy bool
+	switch query.Get("mode") {
+	case "", "exact":
+	case "fuzzy":
+		fuzzy = true
+	default:
+		respond.BadRequest(w, r, "mode", "mode 必须是 exact 或 fuzzy")
+		return
+	}
+
+	limit, ok := respond.Limit(w, r, defaultSearchLimit, maxSearchLimit)
+	if !ok {
+		return
+	}
+
+	locations, err := h.storage.SearchLocations(r.Context(), q, fuzzy, limit)
+	if err != nil {
+		respond.StorageError(w, r, h.logger(r), "搜索地点失败", err, zap.String("q", q), zap.Bool("fuzzy", fuzzy))
+		return
+	}
+
+	respond.List(w, locations, respond.Meta{})
+}
+
+// GetLocationHistoryHandler 获取地点的变更历史
+func (h *Handler) GetLocationHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	geonameID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		respond.BadRequest(w, r, "id", "id 必须是整数")
+		return
+	}
+
+	history, err := h.storage.GetLocationHistory(r.Context(), geonameID)
+	if err != nil {
+		respond.StorageError(w, r, h.logger(r), "查询变更历史失败", err, zap.Int64("geoname_id", geonameID))
+		return
+	}
+
+	if len(history) == 0 {
+		respond.NotFound(w, r, "地点不存在")
+		return
+	}
+
+	respond.List(w, history, respond.Meta{})
+}
@@ -0,0 +1,43 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Prefix v1 接口的路径前缀
+const Prefix = "/v1"
+
+// Register 在 r 上注册 v1 的所有路由，路径均加上 prefix（通常为 Prefix，旧的无版本别名传空字符串）
+func (h *Handler) Register(r *mux.Router, prefix string) {
+	// 获取地理位置信息
+	r.HandleFunc(prefix+"/locations", withTimeout(h.timeouts.List, h.GetLocationsHandler)).Methods("GET")
+
+	// 按名称搜索，需在 /locations/{countryCode} 之前注册
+	r.HandleFunc(prefix+"/locations/search", withTimeout(h.timeouts.Search, h.GetSearchLocationsHandler)).Methods("GET")
+
+	// 按坐标查询附近地点，需在 /locations/{countryCode} 之前注册
+	r.HandleFunc(prefix+"/locations/nearby", withTimeout(h.timeouts.Nearby, h.GetNearbyLocationsHandler)).Methods("GET")
+
+	// 获取地点的变更历史
+	r.HandleFunc(prefix+"/locations/id/{id:[0-9]+}/history", withTimeout(h.timeouts.History, h.GetLocationHistoryHandler)).Methods("GET")
+
+	// 按国家代码搜索
+	r.HandleFunc(prefix+"/locations/{countryCode}", withTimeout(h.timeouts.List, h.GetLocationsByCountryHandler)).Methods("GET")
+}
+
+// withTimeout 为请求 context 设置截止时间，存储层的查询超时后被中断，处理器返回 504。
+// 客户端断开时请求 context 同样会被取消，d 为 0 时只跟随客户端
+func withTimeout(d time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	if d <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/unxai/geonames-service/api"
+	"github.com/unxai/geonames-service/api/middleware"
+	v1 "github.com/unxai/geonames-service/api/v1"
+	"github.com/unxai/geonames-service/config"
+	"github.com/unxai/geonames-service/db"
+	"github.com/unxai/geonames-service/health"
+	"github.com/unxai/geonames-service/logger"
+	"github.com/unxai/geonames-service/metrics"
+	"github.com/unxai/geonames-service/progress"
+	"github.com/unxai/geonames-service/storage"
+	"github.com/unxai/geonames-service/storage/postgres"
+	"github.com/unxai/geonames-service/storage/sqlite"
+	"github.com/unxai/geonames-service/tracing"
+	"github.com/unxai/geonames-service/utils"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.uber.org/zap"
+)
+
+// Storage 应用使用的存储后端，由配置 database.driver 决定
+type Storage interface {
+	storage.Storage
+
+	// SetProgress 设置写入进度报告，传入 nil 关闭报告
+	SetProgress(bar *progress.Bar)
+}
+
+// App 持有一个服务实例的全部依赖。配置、日志、连接池和存储只在 New 中构建一次，
+// 再显式传递给 HTTP 处理器、导入器和命令，因此同一进程中可以并存多个不同配置的实例。
+type App struct {
+	Config  *config.Config
+	Logger  *zap.Logger
+	DB      *sql.DB     // 主库
+	Cluster *db.Cluster // 主库及只读副本
+	Storage Storage
+	Metrics *metrics.Server
+	Health  *health.Checker
+	Tracing *tracing.Provider
+}
+
+// New 按配置构建应用实例，不会立即连接数据库，需要时调用 WaitForDB
+func New(cfg *config.Config) (*App, error) {
+	log, err := logger.New(cfg.Log.Level, cfg.Log.Path)
+	if err != nil {
+		return nil, fmt.Errorf("初始化日志失败: %w", err)
+	}
+
+	tp, err := tracing.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Open(cfg)
+	if err != nil {
+		tp.Shutdown(context.Background())
+		return nil, err
+	}
+
+	cluster, err := db.NewCluster(conn, cfg, log)
+	if err != nil {
+		conn.Close()
+		tp.Shutdown(context.Background())
+		return nil, err
+	}
+
+	a := &App{
+		Config:  cfg,
+		Logger:  log,
+		DB:      conn,
+		Cluster: cluster,
+		Storage: newStorage(cfg, conn, cluster, log),
+		Metrics: metrics.NewServer(cluster),
+		Tracing: tp,
+	}
+
+	// 就绪检查以主库为准，迁移版本与 CLI 使用的迁移来源一致
+	migrator, err := a.Migrator("")
+	if err == nil {
+		a.Health, err = health.NewChecker(conn, migrator, cfg.Server.ReadinessTimeout, log)
+	}
+	if err != nil {
+		cluster.Close()
+		tp.Shutdown(context.Background())
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// newStorage 按 database.driver 创建存储后端
+func newStorage(cfg *config.Config, conn *sql.DB, cluster *db.Cluster, log *zap.Logger) Storage {
+	if cfg.Database.Driver == config.DriverSQLite {
+		return sqlite.NewSQLiteStorage(conn, cfg.Download.BatchSize, log)
+	}
+
+	storage := postgres.NewPostgresStorage(conn, cfg.Download.BatchSize, log)
+	storage.SetReader(cluster)
+	return storage
+}
+
+// StartReplicaHealthChecks 开始定期检查只读副本，HTTP 服务启动时调用
+func (a *App) StartReplicaHealthChecks() {
+	a.Cluster.StartHealthChecks(a.Config.Database.ReplicaHealthCheckInterval)
+}
+
+// WaitForDB 等待数据库可用，超时时间和重试间隔由配置决定
+func (a *App) WaitForDB(ctx context.Context) error {
+	return db.WaitForDB(ctx, a.DB, a.Config, a.Logger)
+}
+
+// Router 创建注册了所有接口、/metrics 和健康检查的路由，并套上请求ID、访问日志和 panic 恢复中间件。
+// 除 /metrics 和健康检查外，每个请求都会创建 span，存储查询记录为其子 span
+func (a *App) Router() http.Handler {
+	return middleware.Wrap(a.routes(), a.Logger, a.Metrics.ObserveRequest)
+}
+
+// routes 注册所有路由，不含外层中间件
+func (a *App) routes() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(
+		otelmux.Middleware(a.Config.Tracing.ServiceName,
+			otelmux.WithTracerProvider(a.Tracing.TracerProvider()),
+			otelmux.WithPropagators(tracing.Propagator),
+			otelmux.WithFilter(traced)),
+		middleware.RouteTemplate,
+		middleware.TraceLog,
+	)
+	router.Handle("/metrics", a.Metrics.Handler()).Methods("GET")
+	router.HandleFunc("/healthz", a.Health.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", a.Health.Readiness).Methods("GET")
+	timeouts := a.Config.Server.QueryTimeout
+	api.RegisterRoutes(router, a.Metrics.Storage(tracing.Storage(a.Storage)), a.Logger, v1.Timeouts{
+		List:    timeouts.List,
+		Search:  timeouts.Search,
+		Nearby:  timeouts.Nearby,
+		History: timeouts.History,
+	})
+	return router
+}
+
+// traced 抓取和探测请求频繁且没有排查价值，不创建 span
+func traced(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/metrics", "/healthz", "/readyz":
+		return false
+	}
+	return true
+}
+
+// Migrator 创建迁移器，dir 为空时使用配置 database.migrations_dir，仍为空则使用内嵌迁移
+func (a *App) Migrator(dir string) (*db.Migrator, error) {
+	if dir == "" {
+		dir = a.Config.Database.MigrationsDir
+	}
+	return db.NewMigrator(a.DB, a.Config.Database.Driver, dir, a.Logger)
+}
+
+// Downloader 创建GeoNames数据下载器
+func (a *App) Downloader() *utils.Downloader {
+	return utils.NewDownloader(a.Config.Download.URL, a.Config.Download.Workers, a.Logger)
+}
+
+// Close 关闭主库和副本的连接池，导出剩余的 span 并刷新日志
+func (a *App) Close() error {
+	err := a.Cluster.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.Tracing.Shutdown(ctx); err != nil {
+		a.Logger.Warn("导出剩余的追踪数据失败", zap.Error(err))
+	}
+
+	a.Logger.Sync()
+	return err
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+	"github.com/unxai/geonames-service/api"
+	v1 "github.com/unxai/geonames-service/api/v1"
+	"github.com/unxai/geonames-service/config"
+)
+
+// internalRoutes 不属于版本化 API 的运维路由，文档中须标记 x-internal
+var internalRoutes = map[string]bool{
+	"/healthz":      true,
+	"/readyz":       true,
+	"/metrics":      true,
+	"/openapi.json": true,
+	"/docs":         true,
+}
+
+// pathParamPattern 路由模板中带正则的路径参数，文档中的路径参数不带正则
+var pathParamPattern = regexp.MustCompile(`\{(\w+):[^}]+\}`)
+
+// newTestApp 使用临时目录中的 SQLite 数据库创建应用实例，不执行迁移
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	dir := t.TempDir()
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.Path = filepath.Join(dir, "geonames.db")
+	cfg.Log.Path = filepath.Join(dir, "geonames.log")
+
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatalf("创建应用失败: %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+func loadSpec(t *testing.T) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromData(api.OpenAPISpec)
+	if err != nil {
+		t.Fatalf("加载OpenAPI文档失败: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("OpenAPI文档无效: %v", err)
+	}
+	return doc
+}
+
+// TestRoutesDocumented 服务注册的每条路由都必须在文档中声明：v1 路由正常声明，
+// internalRoutes 中的运维路由标记 x-internal，其余无版本路由只能是 v1 的别名且标记 deprecated。
+// 新增路由时忘记更新文档会失败
+func TestRoutesDocumented(t *testing.T) {
+	doc := loadSpec(t)
+
+	undocumented := map[string]bool{}
+	for path := range doc.Paths.Map() {
+		undocumented[path] = true
+	}
+
+	err := newTestApp(t).routes().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			// 没有路径的子路由
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		path := pathParamPattern.ReplaceAllString(tpl, "{$1}")
+		delete(undocumented, path)
+
+		item := doc.Paths.Value(path)
+		if item == nil {
+			t.Errorf("路由 %s 未在 openapi.yaml 中声明", tpl)
+			return nil
+		}
+		for _, method := range methods {
+			op := item.GetOperation(method)
+			if op == nil {
+				t.Errorf("路由 %s %s 未在 openapi.yaml 中声明", method, tpl)
+				continue
+			}
+			_, internal := op.Extensions["x-internal"]
+			switch {
+			case strings.HasPrefix(path, v1.Prefix+"/"):
+				if op.Deprecated || internal {
+					t.Errorf("%s %s 是 v1 接口，不应标记 deprecated 或 x-internal", method, path)
+				}
+			case internalRoutes[path]:
+				if !internal {
+					t.Errorf("%s %s 是运维接口，须标记 x-internal", method, path)
+				}
+			default:
+				if doc.Paths.Value(v1.Prefix+path) == nil || !op.Deprecated {
+					t.Errorf("%s %s 不是 v1 的别名或未标记 deprecated，新接口须加版本前缀", method, path)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path := range undocumented {
+		t.Errorf("openapi.yaml 中的 %s 没有对应的路由", path)
+	}
+}
+
+// TestHealthResponsesMatchSpec 未迁移的数据库上就绪检查失败，存活检查仍然通过，响应均符合文档
+func TestHealthResponsesMatchSpec(t *testing.T) {
+	specRouter, err := gorillamux.NewRouter(loadSpec(t))
+	if err != nil {
+		t.Fatalf("创建OpenAPI路由失败: %v", err)
+	}
+	handler := newTestApp(t).Router()
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Fatalf("GET %s 状态码 = %d，期望 %d，响应: %s", tt.url, rec.Code, tt.status, rec.Body)
+		}
+
+		route, pathParams, err := specRouter.FindRoute(req)
+		if err != nil {
+			t.Fatalf("文档中没有 GET %s: %v", tt.url, err)
+		}
+		err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: &openapi3filter.RequestValidationInput{Request: req, PathParams: pathParams, Route: route},
+			Status:                 rec.Code,
+			Header:                 rec.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true, MultiError: true},
+		})
+		if err != nil {
+			t.Errorf("GET %s 响应不符合文档: %v\n响应: %s", tt.url, err, rec.Body)
+		}
+	}
+}
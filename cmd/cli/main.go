@@ -1,100 +1,443 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/unxai/geonames-service/app"
 	"github.com/unxai/geonames-service/config"
 	"github.com/unxai/geonames-service/db"
-	"github.com/unxai/geonames-service/logger"
-	"github.com/unxai/geonames-service/utils"
+	"github.com/unxai/geonames-service/diff"
+	"github.com/unxai/geonames-service/metrics"
+	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/progress"
+	"github.com/unxai/geonames-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configFile  string // --config 指定的配置文件
+	cfg         *config.Config
+	application *app.App // 由 initialize 构建，命令通过它获取依赖
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "geonames-cli",
 	Short: "GeoNames CLI工具",
 	Long:  `GeoNames CLI工具用于管理GeoNames数据。`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// 参数已解析成功，之后的运行时错误不再打印用法
+		cmd.SilenceUsage = true
+		return initialize()
+	},
+}
+
+// loadConfig 在解析命令行参数后加载配置文件
+func loadConfig() error {
+	var err error
+	cfg, err = config.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("加载配置文件失败: %w", err)
+	}
+	return nil
+}
+
+// initialize 加载并校验配置，构建应用实例并等待数据库可用
+func initialize() error {
+	// 加载配置文件
+	if err := loadConfig(); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var err error
+	application, err = app.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	// 初始化数据库连接
+	if err := application.WaitForDB(context.Background()); err != nil {
+		return fmt.Errorf("初始化数据库连接失败: %w", err)
+	}
+
+	return nil
 }
 
-// 迁移命令
+// 迁移命令，不带子命令时等同于 migrate up。
+// 迁移失败时返回错误，进程以非零状态退出，部署流水线据此中止发布
 var migrateCmd = &cobra.Command{
 	Use:   "migrate",
 	Short: "执行数据库迁移",
 	Long:  `执行数据库迁移脚本，创建或更新数据库表结构。`,
-	Run: func(cmd *cobra.Command, args []string) {
-		if err := db.Migrate(); err != nil {
-			logger.Logger.Error("数据库迁移失败", zap.Error(err))
-			return
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return migrateUpCmd.RunE(cmd, args)
+	},
+}
+
+var migrationsDir string // 覆盖内嵌迁移脚本的目录
+
+// newMigrator 创建迁移器，--migrations-dir 优先于配置 database.migrations_dir
+func newMigrator() (*db.Migrator, error) {
+	return application.Migrator(migrationsDir)
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "应用所有未执行的迁移",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, err := newMigrator()
+		if err != nil {
+			return fmt.Errorf("数据库迁移失败: %w", err)
+		}
+		if err := migrator.Up(); err != nil {
+			return fmt.Errorf("数据库迁移失败: %w", err)
+		}
+		application.Logger.Info("数据库迁移成功")
+		return nil
+	},
+}
+
+var migrateDownSteps int
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "回滚最近应用的迁移",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, err := newMigrator()
+		if err != nil {
+			return fmt.Errorf("回滚迁移失败: %w", err)
+		}
+		if err := migrator.Down(migrateDownSteps); err != nil {
+			return fmt.Errorf("回滚迁移失败: %w", err)
+		}
+		application.Logger.Info("回滚迁移成功", zap.Int("steps", migrateDownSteps))
+		return nil
+	},
+}
+
+var migrateToCmd = &cobra.Command{
+	Use:   "to <version>",
+	Short: "迁移到指定版本（向上应用或向下回滚）",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("无效的版本号: %q", args[0])
+		}
+		migrator, err := newMigrator()
+		if err != nil {
+			return fmt.Errorf("数据库迁移失败: %w", err)
+		}
+		if err := migrator.To(version); err != nil {
+			return fmt.Errorf("数据库迁移失败: %w", err)
+		}
+		application.Logger.Info("数据库迁移成功", zap.Int64("version", version))
+		return nil
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "查看迁移状态",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, err := newMigrator()
+		if err != nil {
+			return fmt.Errorf("查询迁移状态失败: %w", err)
+		}
+		statuses, err := migrator.Status()
+		if err != nil {
+			return fmt.Errorf("查询迁移状态失败: %w", err)
+		}
+
+		fmt.Printf("%-8s %-36s %-10s %s\n", "版本", "名称", "状态", "应用时间")
+		for _, st := range statuses {
+			state, appliedAt := "未应用", ""
+			if st.Applied {
+				state = "已应用"
+				appliedAt = st.AppliedAt.Format(time.RFC3339)
+			}
+			switch {
+			case st.Missing:
+				state = "文件缺失"
+			case st.Modified:
+				state = "已修改"
+			}
+			fmt.Printf("%-8d %-36s %-10s %s\n", st.Version, st.Name, state, appliedAt)
+		}
+		return nil
+	},
+}
+
+// 配置命令，只加载配置，不连接数据库
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "配置管理",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfig()
+	},
+}
+
+var configCheckCmd = &cobra.Command{
+	Use:          "check",
+	Short:        "校验配置并输出合并后的有效配置",
+	Long:         `合并默认值、配置文件和GEONAMES_*环境变量，输出有效配置（敏感信息已脱敏），并报告所有配置问题。`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := yaml.Marshal(cfg.Redacted())
+		if err != nil {
+			return fmt.Errorf("序列化配置失败: %w", err)
+		}
+		fmt.Print(string(out))
+
+		if err := cfg.Validate(); err != nil {
+			return err
 		}
-		logger.Logger.Info("数据库迁移成功")
+		fmt.Println("配置有效")
+		return nil
 	},
 }
 
+var (
+	dryRun     bool   // 只比较差异，不写入数据库
+	diffOutput string // 完整差异的JSONL输出文件
+	fullSync   bool   // 全量同步，删除本次导入中未出现的记录
+	maxDelete  int    // 全量同步时一次最多删除的记录数
+)
+
 // 下载命令
 var downloadCmd = &cobra.Command{
 	Use:   "download",
 	Short: "下载并更新GeoNames数据",
 	Long:  `从GeoNames下载最新的地理位置数据并更新到数据库中。`,
-	Run: func(cmd *cobra.Command, args []string) {
-		if err := downloadAndSaveData(); err != nil {
-			logger.Logger.Error("下载数据失败", zap.Error(err))
-			return
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		// --output 只在比较差异时写入，不带 --dry-run 时拒绝执行，避免误以为导入前已保存差异
+		if diffOutput != "" && !dryRun {
+			return fmt.Errorf("--output 只能与 --dry-run 一起使用")
+		}
+		return nil
+	},
+	// 导入失败（包括全量同步超过删除上限被拒绝）时返回错误，进程以非零状态退出，供定时任务和CI判断
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("max-delete") {
+			maxDelete = cfg.Download.MaxDelete
 		}
-		logger.Logger.Info("数据下载并保存成功")
+		if dryRun {
+			return runDiff(cmd.Context())
+		}
+
+		// 一次导入为一个根 span，下载、解析和各个存储操作为其子 span
+		ctx, span := application.Tracing.Start(cmd.Context(), "import", attribute.Bool("import.full_sync", fullSync))
+		log := application.Logger.With(tracing.LogFields(ctx)...)
+		err := downloadAndSaveData(ctx)
+		tracing.End(span, err)
+		if err != nil {
+			return fmt.Errorf("导入数据失败: %w", err)
+		}
+		log.Info("数据下载并保存成功")
+		return nil
 	},
 }
 
-func downloadAndSaveData() error {
+// 差异命令
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "比较GeoNames数据与数据库的差异",
+	Long:  `下载最新的GeoNames数据并与locations表比较，报告将要插入、更新和消失的记录，不写入数据库。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDiff(cmd.Context())
+	},
+}
+
+// runDiff 在 diff span 中比较数据差异
+func runDiff(ctx context.Context) error {
+	ctx, span := application.Tracing.Start(ctx, "diff")
+	log := application.Logger.With(tracing.LogFields(ctx)...)
+	err := downloadAndDiff(ctx, log)
+	tracing.End(span, err)
+	if err != nil {
+		return fmt.Errorf("比较数据差异失败: %w", err)
+	}
+	return nil
+}
+
+func downloadAndSaveData(ctx context.Context) (err error) {
+	// 无论成功与否都上报导入指标
+	m := metrics.NewImport()
+	defer func() {
+		m.Finish(err)
+		reportImportMetrics(m)
+	}()
+
 	// 下载数据
-	locations, err := utils.DownloadGeoData()
+	downloader := application.Downloader()
+	locations, err := downloader.DownloadGeoData(ctx)
 	if err != nil {
 		return fmt.Errorf("下载数据失败: %w", err)
 	}
+	stats := downloader.Stats()
+	m.SetParsed(stats.Parsed, stats.Rejected)
 
-	// 获取存储实例
-	storage := db.GetStorage()
+	// 获取存储实例，进度报告设置在底层存储上
+	storage := tracing.Storage(application.Storage)
+
+	importID, err := storage.StartImport(ctx, fullSync)
+	if err != nil {
+		return err
+	}
 
-	if err := storage.SaveLocations(locations); err != nil {
+	// 报告数据库写入进度
+	bar := progress.Start(application.Logger, "写入", progress.UnitCount, int64(len(locations)))
+	application.Storage.SetProgress(bar)
+	defer application.Storage.SetProgress(nil)
+	err = storage.SaveLocations(ctx, importID, locations)
+	bar.Finish()
+	if err != nil {
 		return fmt.Errorf("批量保存数据失败: %w", err)
 	}
 
+	// 全量同步时删除本次导入中未出现的记录
+	var deleted int64
+	if fullSync {
+		deleted, err = storage.DeleteStaleLocations(ctx, importID, maxDelete)
+		if err != nil {
+			return fmt.Errorf("删除过期记录失败: %w", err)
+		}
+	}
+
+	m.SetWritten(int64(len(locations)), deleted)
+	return storage.FinishImport(ctx, importID, int64(len(locations)), deleted)
+}
+
+// reportImportMetrics 按配置将导入指标写入 textfile 或推送到 Pushgateway，失败只记录日志
+func reportImportMetrics(m *metrics.Import) {
+	if path := cfg.Metrics.Textfile; path != "" {
+		if err := m.WriteTextfile(path); err != nil {
+			application.Logger.Warn("上报导入指标失败", zap.String("textfile", path), zap.Error(err))
+		}
+	}
+	if url := cfg.Metrics.PushURL; url != "" {
+		if err := m.Push(url, cfg.Metrics.Job); err != nil {
+			application.Logger.Warn("上报导入指标失败", zap.String("push_url", config.RedactDSN(url)), zap.Error(err))
+		}
+	}
+}
+
+func downloadAndDiff(ctx context.Context, log *zap.Logger) error {
+	// 下载数据
+	locations, err := application.Downloader().DownloadGeoData(ctx)
+	if err != nil {
+		return fmt.Errorf("下载数据失败: %w", err)
+	}
+
+	// 可选地将完整差异写入JSONL文件
+	var w io.Writer
+	var out *bufio.Writer
+	if diffOutput != "" {
+		f, err := os.Create(diffOutput)
+		if err != nil {
+			return fmt.Errorf("创建差异文件失败: %w", err)
+		}
+		defer f.Close()
+		out = bufio.NewWriter(f)
+		w = out
+	}
+
+	storage := tracing.Storage(application.Storage)
+	iterate := func(fn func(models.Location) error) error {
+		return storage.IterateLocations(ctx, fn)
+	}
+
+	summary, err := diff.Compare(locations, iterate, w)
+	if err != nil {
+		return fmt.Errorf("比较数据失败: %w", err)
+	}
+	if out != nil {
+		if err := out.Flush(); err != nil {
+			return fmt.Errorf("写入差异文件失败: %w", err)
+		}
+	}
+
+	printSummary(summary)
+	log.Info("数据差异比较完成",
+		zap.Int("inserts", summary.Inserts),
+		zap.Int("updates", summary.Updates),
+		zap.Int("deletes", summary.Deletes),
+		zap.Int("unchanged", summary.Unchanged),
+	)
+
 	return nil
 }
 
+// printSummary 在终端输出差异统计
+func printSummary(s *diff.Summary) {
+	fmt.Printf("新增: %d\n", s.Inserts)
+	fmt.Printf("更新: %d\n", s.Updates)
+	fmt.Printf("消失: %d\n", s.Deletes)
+	fmt.Printf("未变: %d\n", s.Unchanged)
+
+	fields := make([]string, 0, len(s.FieldUpdates))
+	for field := range s.FieldUpdates {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fmt.Printf("  %-18s %d\n", field, s.FieldUpdates[field])
+	}
+}
+
 func init() {
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "配置文件路径（默认为当前目录下的config.yaml）")
+
+	downloadCmd.Flags().BoolVar(&dryRun, "dry-run", false, "只比较与数据库的差异，不写入数据")
+	downloadCmd.Flags().StringVar(&diffOutput, "output", "", "将完整差异以JSONL格式写入指定文件（配合--dry-run使用）")
+	downloadCmd.Flags().BoolVar(&fullSync, "full-sync", false, "全量同步：删除本次导入中未出现的记录（软删除）")
+	downloadCmd.Flags().IntVar(&maxDelete, "max-delete", 0, "全量同步时一次最多删除的记录数，负数表示不限制（未指定时使用配置download.max_delete）")
+	diffCmd.Flags().StringVar(&diffOutput, "output", "", "将完整差异以JSONL格式写入指定文件")
+
+	migrateCmd.PersistentFlags().StringVar(&migrationsDir, "migrations-dir", "", "使用指定目录中的迁移脚本代替内嵌的迁移（默认使用配置database.migrations_dir）")
+	migrateDownCmd.Flags().IntVar(&migrateDownSteps, "steps", 1, "回滚的迁移数量")
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateToCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+
+	configCmd.AddCommand(configCheckCmd)
+
+	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(diffCmd)
 	rootCmd.AddCommand(migrateCmd)
 }
 
 func main() {
-	// 加载配置文件
-	cfg, err := config.LoadConfig()
+	err := rootCmd.Execute()
 	if err != nil {
-		fmt.Printf("加载配置文件失败: %v\n", err)
-		os.Exit(1)
-	}
-
-	// 初始化日志
-	if err := logger.InitLogger(cfg.Log.Level, cfg.Log.Path); err != nil {
-		fmt.Printf("初始化日志失败: %v\n", err)
-		os.Exit(1)
+		if application != nil {
+			application.Logger.Error("执行命令失败", zap.Error(err))
+		} else {
+			fmt.Printf("执行命令失败: %v\n", err)
+		}
 	}
 
-	// 初始化数据库连接
-	if db := db.GetDB(); db == nil {
-		logger.Logger.Error("初始化数据库连接失败")
-		os.Exit(1)
-	}
-	defer func() {
-		if err := db.CloseDB(); err != nil {
-			logger.Logger.Error("关闭数据库连接失败", zap.Error(err))
+	if application != nil {
+		if err := application.Close(); err != nil {
+			application.Logger.Error("关闭数据库连接失败", zap.Error(err))
 		}
-	}()
+	}
 
-	if err := rootCmd.Execute(); err != nil {
-		logger.Logger.Error("执行命令失败", zap.Error(err))
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -2,22 +2,28 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/spf13/cobra"
-	"github.com/unxai/geonames-service/api"
+	"github.com/unxai/geonames-service/app"
 	"github.com/unxai/geonames-service/config"
-	"github.com/unxai/geonames-service/db"
-	"github.com/unxai/geonames-service/logger"
+	"github.com/unxai/geonames-service/tlscert"
 	"go.uber.org/zap"
 )
 
+var (
+	configFile string      // --config 指定的配置文件
+	log        *zap.Logger // 应用实例创建后可用，供 main 记录错误
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "geonames-server",
 	Short: "GeoNames HTTP服务器",
@@ -28,55 +34,110 @@ var rootCmd = &cobra.Command{
 }
 
 func runServer() error {
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfig(configFile)
 	if err != nil {
 		return fmt.Errorf("加载配置失败: %w", err)
 	}
-
-	// 初始化日志
-	if err := logger.InitLogger(cfg.Log.Level, cfg.Log.Path); err != nil {
-		return fmt.Errorf("初始化日志失败: %w", err)
+	if err := cfg.Validate(); err != nil {
+		return err
 	}
 
-	// 初始化数据库连接
-	_ = db.GetDB()
+	// 构建应用实例（日志、连接池、存储）
+	application, err := app.New(cfg)
+	if err != nil {
+		return err
+	}
+	log = application.Logger
 	defer func() {
-		if err := db.CloseDB(); err != nil {
-			logger.Logger.Error("关闭数据库连接失败", zap.Error(err))
+		if err := application.Close(); err != nil {
+			log.Error("关闭数据库连接失败", zap.Error(err))
 		}
 	}()
 
+	// 初始化数据库连接。数据库暂不可用时不退出，避免容器反复重启
+	if err := application.WaitForDB(context.Background()); err != nil {
+		log.Error("数据库不可用，服务以降级模式启动", zap.Error(err))
+	}
+
+	// API 读查询优先使用只读副本，与夜间导入分担主库压力
+	application.StartReplicaHealthChecks()
+
 	// 设置路由
-	router := mux.NewRouter()
-	api.RegisterRoutes(router)
+	router := application.Router()
 
 	// 创建HTTP服务器
-	addr := fmt.Sprintf(":%d", cfg.Server.Port)
+	addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: router,
+		Addr:              addr,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	// TLS 握手失败等连接级错误写入日志而不是标准错误输出
+	if errorLog, err := zap.NewStdLogAt(log, zap.WarnLevel); err == nil {
+		srv.ErrorLog = errorLog
+	}
+
+	// 配置了证书时启用 HTTPS，证书文件变化后自动重新加载
+	scheme := "http"
+	if cfg.Server.TLS.CertFile != "" {
+		reloader, err := tlscert.NewReloader(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile, log)
+		if err != nil {
+			return err
+		}
+		defer reloader.Close()
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+		scheme = "https"
 	}
 
 	// 创建用于接收操作系统信号的通道
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// 启动HTTP服务器
+	// 启动HTTP服务器，监听失败（如端口被占用）时直接退出
+	serveErr := make(chan error, 1)
 	go func() {
-		logger.Logger.Info("服务器启动",
-			zap.String("address", fmt.Sprintf("http://localhost%s", addr)),
+		log.Info("服务器启动",
+			zap.String("address", fmt.Sprintf("%s://%s", scheme, addr)),
 		)
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			logger.Logger.Error("服务器异常退出", zap.Error(err))
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			serveErr <- err
 		}
 	}()
 
 	// 等待信号
-	<-sigChan
-	logger.Logger.Info("正在关闭服务器...")
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("服务器异常退出: %w", err)
+	case <-sigChan:
+	}
+	log.Info("正在关闭服务器...")
+
+	// 先让就绪检查失败，等待负载均衡摘除本实例后再停止接收请求，期间仍正常处理请求
+	application.Health.SetShuttingDown()
+	if delay := cfg.Server.ShutdownDelay; delay > 0 {
+		log.Info("等待负载均衡摘除实例", zap.Duration("delay", delay))
+		select {
+		case <-time.After(delay):
+		case <-sigChan:
+			log.Info("再次收到退出信号，立即关闭")
+		}
+	}
 
-	// 创建一个带超时的上下文用于优雅关闭
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// 停止接收新连接，最多等待 shutdown_timeout 让进行中的请求完成
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	// 优雅关闭服务器
@@ -84,21 +145,18 @@ func runServer() error {
 		return fmt.Errorf("服务器关闭失败: %w", err)
 	}
 
-	logger.Logger.Info("服务器已关闭")
+	log.Info("服务器已关闭")
 	return nil
 }
 
-func main() {
-	// 加载配置文件
-	_, err := config.LoadConfig()
-	if err != nil {
-		fmt.Printf("加载配置文件失败: %v\n", err)
-		os.Exit(1)
-	}
+func init() {
+	rootCmd.Flags().StringVar(&configFile, "config", "", "配置文件路径（默认为当前目录下的config.yaml）")
+}
 
+func main() {
 	if err := rootCmd.Execute(); err != nil {
-		if logger.Logger != nil {
-			logger.Logger.Error("执行命令失败", zap.Error(err))
+		if log != nil {
+			log.Error("执行命令失败", zap.Error(err))
 		} else {
 			fmt.Printf("执行命令失败: %v\n", err)
 		}
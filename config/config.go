@@ -1,63 +1,230 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	Database struct {
-		Host     string
-		Port     int
-		User     string
-		Password string
-		DBName   string
-		SSLMode  string
-	}
+		Driver string `mapstructure:"driver"` // 存储后端：postgres 或 sqlite
+		Path   string `mapstructure:"path"`   // SQLite 数据库文件路径，仅 driver 为 sqlite 时使用
+
+		Host         string `mapstructure:"host"`
+		Port         int    `mapstructure:"port"`
+		User         string `mapstructure:"user"`
+		Password     string `mapstructure:"password"`
+		PasswordFile string `mapstructure:"password_file"` // 从文件读取密码（如Kubernetes Secret挂载），优先于password
+		DBName       string `mapstructure:"dbname"`
+		SSLMode      string `mapstructure:"sslmode"`
+
+		MigrationsDir string `mapstructure:"migrations_dir"` // 自定义迁移目录，为空时使用内嵌的迁移脚本
+
+		// 连接池参数
+		MaxOpenConns    int           `mapstructure:"max_open_conns"`     // 最大打开连接数
+		MaxIdleConns    int           `mapstructure:"max_idle_conns"`     // 最大空闲连接数
+		ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`  // 连接最大生命周期
+		ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // 连接最大空闲时间
+
+		// 只读副本，API 的读查询轮询健康的副本，写入和迁移始终使用主库
+		Replicas                   []string      `mapstructure:"replicas"`                      // 副本连接字符串（URL 或 key=value 形式）
+		ReplicaHealthCheckInterval time.Duration `mapstructure:"replica_health_check_interval"` // 副本健康检查间隔
+
+		// 启动时连接重试
+		ConnectTimeout   time.Duration `mapstructure:"connect_timeout"`    // 启动时等待数据库可用的最长时间
+		RetryInterval    time.Duration `mapstructure:"retry_interval"`     // 首次重试间隔，之后指数退避
+		RetryMaxInterval time.Duration `mapstructure:"retry_max_interval"` // 最大重试间隔
+	} `mapstructure:"database"`
 	Server struct {
-		Port int
-		Host string
-	}
+		Port int    `mapstructure:"port"`
+		Host string `mapstructure:"host"` // 监听地址，为空时监听所有网卡
+
+		// 连接超时，防止慢速客户端长期占用连接，0 表示不限制
+		ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"` // 读取请求头的超时
+		ReadTimeout       time.Duration `mapstructure:"read_timeout"`        // 读取整个请求的超时
+		WriteTimeout      time.Duration `mapstructure:"write_timeout"`       // 从读完请求头到写完响应的超时，应大于各接口的查询超时
+		IdleTimeout       time.Duration `mapstructure:"idle_timeout"`        // keep-alive 连接的空闲超时
+		MaxHeaderBytes    int           `mapstructure:"max_header_bytes"`    // 请求头的最大字节数
+		ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout"`    // 停止接收请求后等待进行中请求完成的最长时间
+
+		// HTTPS，证书和私钥同时配置时启用，文件变化后自动重新加载
+		TLS struct {
+			CertFile string `mapstructure:"cert_file"`
+			KeyFile  string `mapstructure:"key_file"`
+		} `mapstructure:"tls"`
+
+		ReadinessTimeout time.Duration `mapstructure:"readiness_timeout"` // /readyz 中数据库检查的总超时
+		ShutdownDelay    time.Duration `mapstructure:"shutdown_delay"`    // 收到退出信号后先让 /readyz 失败，等待该时长再停止接收请求
+
+		// 各接口数据库查询的超时，超时返回 504，0 表示不限制
+		QueryTimeout struct {
+			List    time.Duration `mapstructure:"list"`    // 列表和按国家查询（含 total 统计）
+			Search  time.Duration `mapstructure:"search"`  // 按名称搜索
+			Nearby  time.Duration `mapstructure:"nearby"`  // 附近地点
+			History time.Duration `mapstructure:"history"` // 变更历史
+		} `mapstructure:"query_timeout"`
+	} `mapstructure:"server"`
 	Download struct {
-		URL       string
-		BatchSize int
-	}
+		URL       string `mapstructure:"url"`
+		BatchSize int    `mapstructure:"batch_size"` // 每个事务写入的记录数
+		Workers   int    `mapstructure:"workers"`    // 并发解析的worker数量
+		MaxDelete int    `mapstructure:"max_delete"` // 全量同步时一次最多删除的记录数，负数表示不限制
+	} `mapstructure:"download"`
 	Log struct {
-		Level string
-		Path  string
-	}
+		Level string `mapstructure:"level"`
+		Path  string `mapstructure:"path"`
+	} `mapstructure:"log"`
+	// 导入指标。服务的指标通过 /metrics 抓取；CLI 导入结束即退出，指标写入文件或推送到 Pushgateway
+	Metrics struct {
+		Textfile string `mapstructure:"textfile"` // node_exporter textfile collector 目录下的 *.prom 文件，为空时不写入
+		PushURL  string `mapstructure:"push_url"` // Pushgateway 地址，为空时不推送
+		Job      string `mapstructure:"job"`      // 推送到 Pushgateway 时的 job 名称
+	} `mapstructure:"metrics"`
+	// 链路追踪（OpenTelemetry），按 W3C Trace Context 传播
+	Tracing struct {
+		Exporter    string  `mapstructure:"exporter"`     // 导出方式：none、otlp 或 stdout（输出到标准输出，用于本地调试）
+		Endpoint    string  `mapstructure:"endpoint"`     // OTLP/HTTP 地址（如 http://otel-collector:4318/v1/traces），为空时使用 OTEL_EXPORTER_OTLP_* 环境变量
+		ServiceName string  `mapstructure:"service_name"` // 上报的 service.name
+		SampleRatio float64 `mapstructure:"sample_ratio"` // 根 span 的采样比例，0 到 1；上游已采样的请求始终跟随上游
+	} `mapstructure:"tracing"`
+}
+
+// 支持的存储后端
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite" // 单文件数据库，用于无法运行 PostgreSQL 的离线部署
+)
+
+// 支持的追踪导出方式
+const (
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+)
+
+// EnvPrefix 环境变量前缀，如 GEONAMES_DATABASE_PASSWORD 覆盖 database.password
+const EnvPrefix = "GEONAMES"
+
+// setDefaults 设置所有配置项的默认值。
+// viper 只会为已知的键绑定环境变量，因此每个字段都必须在这里登记。
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("database.driver", DriverPostgres)
+	v.SetDefault("database.path", "./data/geonames.db")
+	v.SetDefault("database.host", "localhost")
+	v.SetDefault("database.port", 5432)
+	v.SetDefault("database.user", "postgres")
+	v.SetDefault("database.password", "")
+	v.SetDefault("database.password_file", "")
+	v.SetDefault("database.dbname", "geonames")
+	v.SetDefault("database.sslmode", "disable")
+	v.SetDefault("database.migrations_dir", "")
+	v.SetDefault("database.max_open_conns", 50)
+	v.SetDefault("database.max_idle_conns", 10)
+	v.SetDefault("database.conn_max_lifetime", 30*time.Minute)
+	v.SetDefault("database.conn_max_idle_time", 5*time.Minute)
+	v.SetDefault("database.replicas", []string{})
+	v.SetDefault("database.replica_health_check_interval", 10*time.Second)
+	v.SetDefault("database.connect_timeout", 30*time.Second)
+	v.SetDefault("database.retry_interval", 500*time.Millisecond)
+	v.SetDefault("database.retry_max_interval", 5*time.Second)
+
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.host", "")
+	v.SetDefault("server.read_header_timeout", 5*time.Second)
+	v.SetDefault("server.read_timeout", 15*time.Second)
+	v.SetDefault("server.write_timeout", 30*time.Second)
+	v.SetDefault("server.idle_timeout", 120*time.Second)
+	v.SetDefault("server.max_header_bytes", 1<<20)
+	v.SetDefault("server.shutdown_timeout", 10*time.Second)
+	v.SetDefault("server.tls.cert_file", "")
+	v.SetDefault("server.tls.key_file", "")
+	v.SetDefault("server.readiness_timeout", 2*time.Second)
+	v.SetDefault("server.shutdown_delay", 5*time.Second)
+	v.SetDefault("server.query_timeout.list", 10*time.Second)
+	v.SetDefault("server.query_timeout.search", 5*time.Second)
+	v.SetDefault("server.query_timeout.nearby", 5*time.Second)
+	v.SetDefault("server.query_timeout.history", 5*time.Second)
+
+	v.SetDefault("download.url", "http://download.geonames.org/export/dump/allCountries.zip")
+	v.SetDefault("download.batch_size", 1000)
+	v.SetDefault("download.workers", 10)
+	v.SetDefault("download.max_delete", 10000)
+
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.path", "./logs/geonames.log")
+
+	v.SetDefault("metrics.textfile", "")
+	v.SetDefault("metrics.push_url", "")
+	v.SetDefault("metrics.job", "geonames_import")
+	v.SetDefault("tracing.exporter", TracingNone)
+	v.SetDefault("tracing.endpoint", "")
+	v.SetDefault("tracing.service_name", "geonames-service")
+	v.SetDefault("tracing.sample_ratio", 1.0)
 }
 
-var cfg Config
+// LoadConfig 加载配置：默认值 < 配置文件 < GEONAMES_* 环境变量。
+// configFile 为空时在当前目录查找 config.yaml，找不到时仅使用默认值和环境变量。
+func LoadConfig(configFile string) (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
 
-// LoadConfig 加载配置文件
-func LoadConfig() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+	} else {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath(".")
+	}
 
-	err := viper.ReadInConfig()
+	// 未显式指定配置文件且当前目录没有 config.yaml 时，仅使用默认值和环境变量
+	err := v.ReadInConfig()
 	if err != nil {
-		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		var notFound viper.ConfigFileNotFoundError
+		if configFile != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		}
 	}
 
-	err = viper.Unmarshal(&cfg)
+	var cfg Config
+	err = v.Unmarshal(&cfg)
 	if err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
 
+	if cfg.Database.PasswordFile != "" {
+		password, err := os.ReadFile(cfg.Database.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取数据库密码文件失败: %w", err)
+		}
+		cfg.Database.Password = strings.TrimSpace(string(password))
+	}
+
 	return &cfg, nil
 }
 
-// GetDSN 获取数据库连接字符串
-func GetDSN() string {
+// DSN 获取数据库连接字符串
+func (c *Config) DSN() string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.DBName,
-		cfg.Database.SSLMode,
+		quoteDSNValue(c.Database.Host),
+		c.Database.Port,
+		quoteDSNValue(c.Database.User),
+		quoteDSNValue(c.Database.Password),
+		quoteDSNValue(c.Database.DBName),
+		quoteDSNValue(c.Database.SSLMode),
 	)
 }
+
+// quoteDSNValue 按 libpq 规则为连接参数加引号，使包含空格或引号的密码也能正确解析
+func quoteDSNValue(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + escaped + "'"
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MaxBatchSize 单批写入的最大记录数。PostgreSQL 单条语句最多 65535 个参数，每行占用 16 个
+const MaxBatchSize = 4000
+
+const redacted = "******"
+
+var (
+	validDrivers   = []string{DriverPostgres, DriverSQLite}
+	validSSLModes  = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	validLogLevels = []string{"debug", "info", "warn", "error"}
+	validExporters = []string{TracingNone, TracingOTLP, TracingStdout}
+)
+
+// ValidationError 汇总配置中的所有问题
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "配置校验失败:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate 检查必填项、取值范围和枚举值，一次性报告所有问题
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Database.Driver {
+	case DriverPostgres:
+		if c.Database.Host == "" {
+			addf("database.host 不能为空")
+		}
+		if c.Database.Port < 1 || c.Database.Port > 65535 {
+			addf("database.port 必须在1到65535之间，当前为 %d", c.Database.Port)
+		}
+		if c.Database.User == "" {
+			addf("database.user 不能为空")
+		}
+		if c.Database.DBName == "" {
+			addf("database.dbname 不能为空")
+		}
+		if !slices.Contains(validSSLModes, c.Database.SSLMode) {
+			addf("database.sslmode 必须是 %s 之一，当前为 %q", strings.Join(validSSLModes, "/"), c.Database.SSLMode)
+		}
+	case DriverSQLite:
+		if c.Database.Path == "" {
+			addf("database.path 不能为空")
+		}
+		if len(c.Database.Replicas) > 0 {
+			addf("database.replicas 仅支持 postgres，sqlite 不能配置只读副本")
+		}
+	default:
+		addf("database.driver 必须是 %s 之一，当前为 %q", strings.Join(validDrivers, "/"), c.Database.Driver)
+	}
+	if c.Database.MaxOpenConns < 1 {
+		addf("database.max_open_conns 必须大于0，当前为 %d", c.Database.MaxOpenConns)
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		addf("database.max_idle_conns 必须在0到max_open_conns之间，当前为 %d", c.Database.MaxIdleConns)
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		addf("database.conn_max_lifetime 不能为负数，当前为 %s", c.Database.ConnMaxLifetime)
+	}
+	if c.Database.ConnMaxIdleTime < 0 {
+		addf("database.conn_max_idle_time 不能为负数，当前为 %s", c.Database.ConnMaxIdleTime)
+	}
+	for i, dsn := range c.Database.Replicas {
+		if strings.TrimSpace(dsn) == "" {
+			addf("database.replicas[%d] 不能为空", i)
+		}
+	}
+	if len(c.Database.Replicas) > 0 && c.Database.ReplicaHealthCheckInterval <= 0 {
+		addf("database.replica_health_check_interval 必须大于0，当前为 %s", c.Database.ReplicaHealthCheckInterval)
+	}
+	if c.Database.ConnectTimeout <= 0 {
+		addf("database.connect_timeout 必须大于0，当前为 %s", c.Database.ConnectTimeout)
+	}
+	if c.Database.RetryInterval <= 0 || c.Database.RetryMaxInterval < c.Database.RetryInterval {
+		addf("database.retry_interval 必须大于0且不超过retry_max_interval，当前为 %s/%s",
+			c.Database.RetryInterval, c.Database.RetryMaxInterval)
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		addf("server.port 必须在1到65535之间，当前为 %d", c.Server.Port)
+	}
+	if c.Server.ReadinessTimeout <= 0 {
+		addf("server.readiness_timeout 必须大于0，当前为 %s", c.Server.ReadinessTimeout)
+	}
+	if c.Server.ShutdownDelay < 0 {
+		addf("server.shutdown_delay 不能为负数，当前为 %s", c.Server.ShutdownDelay)
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"read_timeout", c.Server.ReadTimeout},
+		{"write_timeout", c.Server.WriteTimeout},
+		{"idle_timeout", c.Server.IdleTimeout},
+	} {
+		if t.d < 0 {
+			addf("server.%s 不能为负数，当前为 %s", t.name, t.d)
+		}
+	}
+	if c.Server.MaxHeaderBytes < 1 {
+		addf("server.max_header_bytes 必须大于0，当前为 %d", c.Server.MaxHeaderBytes)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		addf("server.shutdown_timeout 必须大于0，当前为 %s", c.Server.ShutdownTimeout)
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		addf("server.tls.cert_file 和 server.tls.key_file 必须同时配置")
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"list", c.Server.QueryTimeout.List},
+		{"search", c.Server.QueryTimeout.Search},
+		{"nearby", c.Server.QueryTimeout.Nearby},
+		{"history", c.Server.QueryTimeout.History},
+	} {
+		if t.d < 0 {
+			addf("server.query_timeout.%s 不能为负数，当前为 %s", t.name, t.d)
+		}
+		// 超过写超时后连接会被关闭，客户端收不到 504
+		if w := c.Server.WriteTimeout; w > 0 && t.d >= w {
+			addf("server.query_timeout.%s（%s）必须小于 server.write_timeout（%s）", t.name, t.d, w)
+		} else if w > 0 && t.d == 0 {
+			addf("server.query_timeout.%s 不限制时 server.write_timeout 也必须为0", t.name)
+		}
+	}
+
+	if u, err := url.Parse(c.Download.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		addf("download.url 必须是有效的HTTP(S)地址，当前为 %q", c.Download.URL)
+	}
+	if c.Download.BatchSize < 1 || c.Download.BatchSize > MaxBatchSize {
+		addf("download.batch_size 必须在1到%d之间，当前为 %d", MaxBatchSize, c.Download.BatchSize)
+	}
+	if c.Download.Workers < 1 {
+		addf("download.workers 必须大于0，当前为 %d", c.Download.Workers)
+	}
+
+	if !slices.Contains(validLogLevels, c.Log.Level) {
+		addf("log.level 必须是 %s 之一，当前为 %q", strings.Join(validLogLevels, "/"), c.Log.Level)
+	}
+	if c.Log.Path == "" {
+		addf("log.path 不能为空")
+	}
+
+	if c.Metrics.Textfile != "" && !strings.HasSuffix(c.Metrics.Textfile, ".prom") {
+		addf("metrics.textfile 必须以 .prom 结尾（node_exporter 只读取 *.prom），当前为 %q", c.Metrics.Textfile)
+	}
+	if c.Metrics.PushURL != "" {
+		if u, err := url.Parse(c.Metrics.PushURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("metrics.push_url 必须是有效的HTTP(S)地址，当前为 %q", c.Metrics.PushURL)
+		}
+		if c.Metrics.Job == "" {
+			addf("metrics.job 不能为空")
+		}
+	}
+
+	if !slices.Contains(validExporters, c.Tracing.Exporter) {
+		addf("tracing.exporter 必须是 %s 之一，当前为 %q", strings.Join(validExporters, "/"), c.Tracing.Exporter)
+	}
+	if c.Tracing.Exporter != TracingNone {
+		if c.Tracing.ServiceName == "" {
+			addf("tracing.service_name 不能为空")
+		}
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			addf("tracing.sample_ratio 必须在0到1之间，当前为 %g", c.Tracing.SampleRatio)
+		}
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("tracing.endpoint 必须是有效的HTTP(S)地址，当前为 %q", c.Tracing.Endpoint)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Redacted 返回以配置键名组织的有效配置，敏感字段已脱敏，用于展示
+func (c *Config) Redacted() map[string]interface{} {
+	m := toMap(reflect.ValueOf(*c))
+	if db, ok := m["database"].(map[string]interface{}); ok {
+		if c.Database.Password != "" {
+			db["password"] = redacted
+		}
+		replicas := make([]string, len(c.Database.Replicas))
+		for i, dsn := range c.Database.Replicas {
+			replicas[i] = RedactDSN(dsn)
+		}
+		db["replicas"] = replicas
+	}
+	if metrics, ok := m["metrics"].(map[string]interface{}); ok && c.Metrics.PushURL != "" {
+		metrics["push_url"] = RedactDSN(c.Metrics.PushURL)
+	}
+	if tracing, ok := m["tracing"].(map[string]interface{}); ok && c.Tracing.Endpoint != "" {
+		tracing["endpoint"] = RedactDSN(c.Tracing.Endpoint)
+	}
+	return m
+}
+
+// dsnPasswordPattern 匹配 key=value 形式连接字符串中的密码
+var dsnPasswordPattern = regexp.MustCompile(`password=('(\\.|[^'])*'|\S+)`)
+
+// RedactDSN 隐藏连接字符串中的密码，支持 URL 和 key=value 两种形式
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "password="+redacted)
+}
+
+// toMap 按 mapstructure 标签将结构体转换为嵌套 map
+func toMap(v reflect.Value) map[string]interface{} {
+	m := make(map[string]interface{})
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if key == "" {
+			key = strings.ToLower(t.Field(i).Name)
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			m[key] = toMap(field)
+		} else {
+			m[key] = field.Interface()
+		}
+	}
+	return m
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/unxai/geonames-service/config"
+	"go.uber.org/zap"
+)
+
+// healthCheckTimeout 单个副本健康检查的超时时间
+const healthCheckTimeout = 2 * time.Second
+
+// Cluster 由主库和可选的只读副本组成。
+// 写入和迁移始终使用主库；读查询轮询健康的副本，没有健康副本时回退到主库。
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+	log      *zap.Logger
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// replica 一个只读副本及其健康状态
+type replica struct {
+	name    string // 脱敏后的连接字符串，用于日志
+	db      *sql.DB
+	healthy atomic.Bool
+	checked atomic.Bool // 是否完成过健康检查
+}
+
+// NewCluster 按配置打开所有副本的连接池。副本在首次健康检查通过前不会接收读请求
+func NewCluster(primary *sql.DB, cfg *config.Config, log *zap.Logger) (*Cluster, error) {
+	c := &Cluster{
+		primary: primary,
+		log:     log,
+		stop:    make(chan struct{}),
+	}
+
+	for _, dsn := range cfg.Database.Replicas {
+		conn, err := openPool(dsn, cfg)
+		if err != nil {
+			c.closeReplicas()
+			return nil, err
+		}
+		c.replicas = append(c.replicas, &replica{name: config.RedactDSN(dsn), db: conn})
+	}
+
+	return c, nil
+}
+
+// Primary 返回主库连接池
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+// Pools 返回主库和所有副本的连接池，键为 primary、replica-1、replica-2…，用于监控指标
+func (c *Cluster) Pools() map[string]*sql.DB {
+	pools := map[string]*sql.DB{"primary": c.primary}
+	for i, r := range c.replicas {
+		pools[fmt.Sprintf("replica-%d", i+1)] = r.db
+	}
+	return pools
+}
+
+// Reader 返回用于只读查询的连接池：轮询健康的副本，全部不可用时返回主库
+func (c *Cluster) Reader() *sql.DB {
+	n := len(c.replicas)
+	if n == 0 {
+		return c.primary
+	}
+
+	start := c.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := c.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return c.primary
+}
+
+// StartHealthChecks 立即检查一次所有副本，之后每隔 interval 检查一次，直到 Close
+func (c *Cluster) StartHealthChecks(interval time.Duration) {
+	if len(c.replicas) == 0 {
+		return
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			c.checkReplicas()
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkReplicas Ping 每个副本并在状态变化时记录日志
+func (c *Cluster) checkReplicas() {
+	for _, r := range c.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		err := r.db.PingContext(ctx)
+		cancel()
+
+		healthy := err == nil
+		first := !r.checked.Swap(true)
+		if r.healthy.Swap(healthy) != healthy || first {
+			if healthy {
+				c.log.Info("只读副本可用", zap.String("replica", r.name))
+			} else {
+				c.log.Warn("只读副本不可用，读请求将转移到其他副本或主库",
+					zap.String("replica", r.name),
+					zap.Error(err))
+			}
+		}
+	}
+}
+
+// Close 停止健康检查并关闭主库和所有副本的连接池
+func (c *Cluster) Close() error {
+	c.once.Do(func() {
+		close(c.stop)
+	})
+	c.wg.Wait()
+
+	return errors.Join(c.primary.Close(), c.closeReplicas())
+}
+
+func (c *Cluster) closeReplicas() error {
+	var errs []error
+	for _, r := range c.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
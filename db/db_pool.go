@@ -1,95 +1,92 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
-	"sync"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/unxai/geonames-service/config"
-	"github.com/unxai/geonames-service/storage/postgres"
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
 )
 
-var (
-	db   *sql.DB
-	once sync.Once
-)
-
-// GetDB 返回数据库连接池的单例实例
-func GetDB() *sql.DB {
-	once.Do(func() {
-		var err error
-		_, err = config.LoadConfig()
-		if err != nil {
-			panic(fmt.Sprintf("加载配置失败: %v", err))
-		}
-
-		// 初始化数据库连接
-		db, err = sql.Open("postgres", config.GetDSN())
-		if err != nil {
-			panic(fmt.Sprintf("连接数据库失败: %v", err))
-		}
-
-		// 设置连接池参数
-		db.SetMaxOpenConns(50)         // 最大打开连接数
-		db.SetMaxIdleConns(10)         // 最大空闲连接数
-		db.SetConnMaxLifetime(30 * 60) // 连接最大生命周期（30分钟）
+// Open 按配置创建主库连接池。sql.Open 不会立即建立连接，需要时调用 WaitForDB 确认数据库可用
+func Open(cfg *config.Config) (*sql.DB, error) {
+	if cfg.Database.Driver == config.DriverSQLite {
+		return openSQLite(cfg)
+	}
+	return openPool(cfg.DSN(), cfg)
+}
 
-		// 测试连接
-		err = db.Ping()
-		if err != nil {
-			panic(fmt.Sprintf("数据库连接测试失败: %v", err))
+// openSQLite 打开 SQLite 数据库文件，不存在时自动创建
+func openSQLite(cfg *config.Config) (*sql.DB, error) {
+	if dir := filepath.Dir(cfg.Database.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败: %w", err)
 		}
-	})
-
-	return db
-}
+	}
 
-// GetStorage 返回存储实例
-func GetStorage() *postgres.PostgresStorage {
-	return postgres.NewPostgresStorage(GetDB())
+	// WAL 模式允许读写并发；写事务以 BEGIN IMMEDIATE 开始，避免多个连接同时升级写锁时出现 SQLITE_BUSY
+	dsn := cfg.Database.Path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_txlock=immediate"
+	return openDriver("sqlite", dsn, cfg)
 }
 
-// CloseDB 关闭数据库连接池
-func CloseDB() error {
-	if db != nil {
-		return db.Close()
-	}
-	return nil
+// openPool 使用配置中的连接池参数打开 dsn 对应的 PostgreSQL 连接池
+func openPool(dsn string, cfg *config.Config) (*sql.DB, error) {
+	return openDriver("postgres", dsn, cfg)
 }
 
-// Migrate 执行数据库迁移
-func Migrate() error {
-	db := GetDB()
-
-	// 读取migrations目录下的SQL文件
-	files, err := os.ReadDir("db/migrations")
+// openDriver 打开连接池并应用配置中的连接池参数
+func openDriver(driver, dsn string, cfg *config.Config) (*sql.DB, error) {
+	conn, err := sql.Open(driver, dsn)
 	if err != nil {
-		return fmt.Errorf("读取迁移文件失败: %w", err)
+		return nil, fmt.Errorf("连接数据库失败: %w", err)
 	}
 
-	// 按文件名排序执行迁移
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name() < files[j].Name()
-	})
+	// 设置连接池参数
+	conn.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	conn.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	conn.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	return conn, nil
+}
+
+// WaitForDB 在 connect_timeout 内重试 Ping，重试间隔从 retry_interval 开始翻倍，不超过 retry_max_interval。
+// 超时后返回最后一次的错误，连接池仍可继续使用，由 database/sql 在数据库恢复后按需重新建立连接。
+func WaitForDB(ctx context.Context, conn *sql.DB, cfg *config.Config, log *zap.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Database.ConnectTimeout)
+	defer cancel()
+
+	interval := cfg.Database.RetryInterval
+	for attempt := 1; ; attempt++ {
+		err := conn.PingContext(ctx)
+		if err == nil {
+			if attempt > 1 {
+				log.Info("数据库连接成功", zap.Int("attempt", attempt))
+			}
+			return nil
 		}
 
-		content, err := os.ReadFile(filepath.Join("db/migrations", file.Name()))
-		if err != nil {
-			return fmt.Errorf("读取迁移文件内容失败: %w", err)
+		log.Warn("数据库暂不可用，稍后重试",
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", interval),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("数据库连接测试失败: %w", err)
+		case <-time.After(interval):
 		}
 
-		if _, err := db.Exec(string(content)); err != nil {
-			return fmt.Errorf("执行迁移脚本失败(%s): %w", file.Name(), err)
+		interval *= 2
+		if interval > cfg.Database.RetryMaxInterval {
+			interval = cfg.Database.RetryMaxInterval
 		}
 	}
-
-	return nil
 }
@@ -0,0 +1,461 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/unxai/geonames-service/config"
+	"go.uber.org/zap"
+)
+
+// embeddedMigrations 编译进二进制的迁移脚本，使CLI可以在任意工作目录下执行迁移
+//
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var embeddedMigrations embed.FS
+
+const (
+	// migrationLockID 迁移使用的 PostgreSQL advisory lock 键，防止多个实例同时迁移
+	migrationLockID = 7261865319
+)
+
+// dialect 迁移在不同数据库上的差异
+type dialect struct {
+	dir         string // 内嵌迁移脚本所在目录
+	lock        string // 获取迁移锁的语句，为空表示不加锁
+	unlock      string
+	createTable string // 创建 schema_migrations 表
+	tableExists string // 查询 schema_migrations 表是否存在，只读
+}
+
+var dialects = map[string]dialect{
+	config.DriverPostgres: {
+		dir:    "migrations",
+		lock:   fmt.Sprintf("SELECT pg_advisory_lock(%d)", migrationLockID),
+		unlock: fmt.Sprintf("SELECT pg_advisory_unlock(%d)", migrationLockID),
+		createTable: `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version BIGINT PRIMARY KEY,
+				name VARCHAR(200) NOT NULL,
+				checksum CHAR(64) NOT NULL,
+				applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)`,
+		tableExists: "SELECT to_regclass('schema_migrations') IS NOT NULL",
+	},
+	// SQLite 的写事务本身互斥，并发迁移时后提交的一方会因 schema_migrations 主键冲突而失败，无需额外加锁
+	config.DriverSQLite: {
+		dir: "migrations/sqlite",
+		createTable: `
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				checksum TEXT NOT NULL,
+				applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+		tableExists: "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')",
+	},
+}
+
+// migrationFilePattern 迁移文件名格式：<版本号>_<名称>.<up|down>.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移脚本
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // up 脚本的 SHA-256
+}
+
+// MigrationStatus 迁移的应用状态
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // 已应用的脚本内容与当前文件不一致
+	Missing   bool // 数据库中已应用，但迁移文件不存在
+}
+
+// appliedMigration schema_migrations 表中的一条记录
+type appliedMigration struct {
+	version   int64
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator 执行版本化的数据库迁移
+type Migrator struct {
+	db      *sql.DB
+	dialect dialect
+	source  fs.FS
+	log     *zap.Logger
+}
+
+// NewMigrator 创建 driver 对应数据库的迁移器。dir 为空时使用内嵌的迁移脚本，否则从 dir 读取自定义迁移
+func NewMigrator(db *sql.DB, driver, dir string, log *zap.Logger) (*Migrator, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("不支持的数据库类型: %s", driver)
+	}
+
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("迁移目录不可用: %w", err)
+		}
+		return &Migrator{db: db, dialect: d, source: os.DirFS(dir), log: log}, nil
+	}
+
+	source, err := fs.Sub(embeddedMigrations, d.dir)
+	if err != nil {
+		return nil, fmt.Errorf("加载内嵌迁移失败: %w", err)
+	}
+	return &Migrator{db: db, dialect: d, source: source, log: log}, nil
+}
+
+// LoadMigrations 读取迁移来源中成对的 up/down 脚本，按版本号升序返回
+func (m *Migrator) LoadMigrations() ([]Migration, error) {
+	files, err := fs.ReadDir(m.source, ".")
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移文件失败: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		match := migrationFilePattern.FindStringSubmatch(file.Name())
+		if match == nil {
+			return nil, fmt.Errorf("迁移文件名格式错误: %s", file.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("迁移版本号错误(%s): %w", file.Name(), err)
+		}
+
+		content, err := fs.ReadFile(m.source, file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("读取迁移文件内容失败: %w", err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("迁移版本号重复: %d (%s, %s)", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("迁移 %d_%s 缺少up脚本", migration.Version, migration.Name)
+		}
+		if migration.Down == "" {
+			return nil, fmt.Errorf("迁移 %d_%s 缺少down脚本", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// LatestVersion 返回迁移来源中的最新版本，没有迁移时为 0
+func (m *Migrator) LatestVersion() (int64, error) {
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// Version 返回数据库中已应用的最高迁移版本，未应用任何迁移时为 0。
+// 只读查询，不加迁移锁也不创建 schema_migrations 表，供健康检查使用
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var version int64
+	if err := m.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("查询迁移版本失败: %w", err)
+	}
+	return version, nil
+}
+
+// Up 应用所有未执行的迁移
+func (m *Migrator) Up() error {
+	return m.To(-1)
+}
+
+// Down 回滚最近应用的 steps 个迁移
+func (m *Migrator) Down(steps int) error {
+	return m.withLock(func(conn *sql.Conn, migrations []Migration, applied map[int64]appliedMigration) error {
+		if err := verifyChecksums(migrations, applied); err != nil {
+			return err
+		}
+
+		versions := appliedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && steps > 0; i, steps = i-1, steps-1 {
+			migration, ok := findMigration(migrations, versions[i])
+			if !ok {
+				return fmt.Errorf("已应用的迁移 %d 缺少迁移文件，无法回滚", versions[i])
+			}
+			if err := m.runMigration(conn, migration, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// To 将数据库迁移到指定版本：高于当前版本时依次应用，低于当前版本时依次回滚。
+// version 为负数表示最新版本。
+func (m *Migrator) To(version int64) error {
+	return m.withLock(func(conn *sql.Conn, migrations []Migration, applied map[int64]appliedMigration) error {
+		if err := verifyChecksums(migrations, applied); err != nil {
+			return err
+		}
+
+		if version < 0 {
+			version = math.MaxInt64
+		}
+
+		// 回滚高于目标版本的迁移
+		versions := appliedVersions(applied)
+		for i := len(versions) - 1; i >= 0 && versions[i] > version; i-- {
+			migration, ok := findMigration(migrations, versions[i])
+			if !ok {
+				return fmt.Errorf("已应用的迁移 %d 缺少迁移文件，无法回滚", versions[i])
+			}
+			if err := m.runMigration(conn, migration, false); err != nil {
+				return err
+			}
+		}
+
+		// 应用不高于目标版本的未执行迁移
+		for _, migration := range migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.runMigration(conn, migration, true); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Status 返回所有迁移的应用状态。
+// 只读查询，不加迁移锁也不创建 schema_migrations 表，表不存在时所有迁移均为未应用
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	ctx := context.Background()
+
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, m.dialect.tableExists).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("查询schema_migrations表失败: %w", err)
+	}
+	applied := map[int64]appliedMigration{}
+	if exists {
+		if applied, err = loadApplied(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if a, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &a.appliedAt
+			status.Modified = a.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	for _, a := range applied {
+		if _, ok := findMigration(migrations, a.version); !ok {
+			appliedAt := a.appliedAt
+			statuses = append(statuses, MigrationStatus{
+				Version:   a.version,
+				Name:      a.name,
+				Applied:   true,
+				AppliedAt: &appliedAt,
+				Missing:   true,
+			})
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// withLock 在持有迁移锁（PostgreSQL advisory lock）的连接上执行迁移操作。
+// 执行前会确保 schema_migrations 表存在，并校验已应用迁移的校验和。
+func (m *Migrator) withLock(fn func(conn *sql.Conn, migrations []Migration, applied map[int64]appliedMigration) error) error {
+	ctx := context.Background()
+
+	migrations, err := m.LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	// advisory lock 属于会话级别，必须在同一个连接上加锁、执行和解锁
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	if m.dialect.lock != "" {
+		if _, err := conn.ExecContext(ctx, m.dialect.lock); err != nil {
+			return fmt.Errorf("获取迁移锁失败: %w", err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(ctx, m.dialect.unlock); err != nil {
+				m.log.Error("释放迁移锁失败", zap.Error(err))
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return fmt.Errorf("创建schema_migrations表失败: %w", err)
+	}
+
+	applied, err := loadApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, migrations, applied)
+}
+
+// querier *sql.DB 和 *sql.Conn 共有的查询方法
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// loadApplied 读取已应用的迁移
+func loadApplied(ctx context.Context, q querier) (map[int64]appliedMigration, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("查询已应用迁移失败: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("读取已应用迁移失败: %w", err)
+		}
+		applied[a.version] = a
+	}
+	return applied, rows.Err()
+}
+
+// runMigration 在事务中执行一个迁移的 up 或 down 脚本，并更新 schema_migrations
+func (m *Migrator) runMigration(conn *sql.Conn, migration Migration, up bool) error {
+	ctx := context.Background()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	direction, script := "up", migration.Up
+	if !up {
+		direction, script = "down", migration.Down
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("执行迁移脚本失败(%d_%s.%s.sql): %w", migration.Version, migration.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+			migration.Version, migration.Name, migration.Checksum)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("更新迁移记录失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交迁移失败: %w", err)
+	}
+
+	m.log.Info("迁移执行成功",
+		zap.Int64("version", migration.Version),
+		zap.String("name", migration.Name),
+		zap.String("direction", direction),
+	)
+	return nil
+}
+
+// verifyChecksums 检查已应用的迁移脚本在应用后是否被修改
+func verifyChecksums(migrations []Migration, applied map[int64]appliedMigration) error {
+	for _, m := range migrations {
+		if a, ok := applied[m.Version]; ok && a.checksum != m.Checksum {
+			return fmt.Errorf("迁移 %d_%s 在应用后被修改（校验和不一致）", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// appliedVersions 返回已应用迁移的版本号（升序）
+func appliedVersions(applied map[int64]appliedMigration) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// findMigration 按版本号查找迁移
+func findMigration(migrations []Migration, version int64) (Migration, bool) {
+	for _, m := range migrations {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
@@ -0,0 +1,2 @@
+-- 删除locations表
+DROP TABLE IF EXISTS locations;
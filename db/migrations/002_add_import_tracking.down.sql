@@ -0,0 +1,10 @@
+-- 删除索引
+DROP INDEX IF EXISTS idx_locations_deleted_at;
+DROP INDEX IF EXISTS idx_locations_import_id;
+
+-- 删除导入跟踪字段
+ALTER TABLE locations DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE locations DROP COLUMN IF EXISTS import_id;
+
+-- 删除imports表
+DROP TABLE IF EXISTS imports;
@@ -0,0 +1,17 @@
+-- 创建imports表，记录每次导入
+CREATE TABLE IF NOT EXISTS imports (
+    id BIGSERIAL PRIMARY KEY,
+    full_sync BOOLEAN NOT NULL DEFAULT FALSE,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    rows_written BIGINT,
+    rows_deleted BIGINT
+);
+
+-- 记录每行最近一次出现在哪次导入中，以及软删除时间
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS import_id BIGINT;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- 创建索引
+CREATE INDEX IF NOT EXISTS idx_locations_import_id ON locations(import_id);
+CREATE INDEX IF NOT EXISTS idx_locations_deleted_at ON locations(deleted_at);
@@ -0,0 +1,6 @@
+-- 删除触发器及触发器函数
+DROP TRIGGER IF EXISTS trg_location_history ON locations;
+DROP FUNCTION IF EXISTS record_location_history();
+
+-- 删除location_history表
+DROP TABLE IF EXISTS location_history;
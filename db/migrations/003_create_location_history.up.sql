@@ -0,0 +1,73 @@
+-- 创建location_history表，记录每个地点每次导入中的字段变更
+CREATE TABLE IF NOT EXISTS location_history (
+    id BIGSERIAL PRIMARY KEY,
+    geoname_id BIGINT NOT NULL,
+    import_id BIGINT,
+    operation VARCHAR(10) NOT NULL,
+    changes JSONB NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 创建索引
+CREATE INDEX IF NOT EXISTS idx_location_history_geoname_id ON location_history(geoname_id, changed_at);
+
+-- 触发器函数：比较新旧行，将有变化的字段以 {"字段": {"old": ..., "new": ...}} 形式写入历史。
+-- 导入ID优先取事务内设置的 geonames.import_id，软删除时据此记录是哪次导入删除了该行。
+CREATE OR REPLACE FUNCTION record_location_history() RETURNS TRIGGER AS $$
+DECLARE
+    current_import BIGINT;
+    old_row JSONB;
+    new_row JSONB;
+    diff JSONB;
+    op VARCHAR(10);
+BEGIN
+    current_import := NULLIF(current_setting('geonames.import_id', true), '')::BIGINT;
+
+    IF TG_OP = 'INSERT' THEN
+        new_row := to_jsonb(NEW) - 'import_id' - 'deleted_at';
+        SELECT jsonb_object_agg(key, jsonb_build_object('old', NULL, 'new', value))
+        INTO diff
+        FROM jsonb_each(new_row);
+
+        INSERT INTO location_history (geoname_id, import_id, operation, changes)
+        VALUES (NEW.geoname_id, COALESCE(current_import, NEW.import_id), 'insert', COALESCE(diff, '{}'::JSONB));
+        RETURN NEW;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO location_history (geoname_id, import_id, operation, changes)
+        VALUES (OLD.geoname_id, current_import, 'delete', '{}'::JSONB);
+        RETURN OLD;
+    END IF;
+
+    IF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        op := 'delete';
+    ELSIF NEW.deleted_at IS NULL AND OLD.deleted_at IS NOT NULL THEN
+        op := 'restore';
+    ELSE
+        op := 'update';
+    END IF;
+
+    old_row := to_jsonb(OLD) - 'import_id' - 'deleted_at';
+    new_row := to_jsonb(NEW) - 'import_id' - 'deleted_at';
+    SELECT jsonb_object_agg(n.key, jsonb_build_object('old', o.value, 'new', n.value))
+    INTO diff
+    FROM jsonb_each(new_row) n
+    JOIN jsonb_each(old_row) o ON o.key = n.key
+    WHERE o.value IS DISTINCT FROM n.value;
+
+    -- 重新导入未变化的行只会更新import_id，不记录历史
+    IF op = 'update' AND diff IS NULL THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO location_history (geoname_id, import_id, operation, changes)
+    VALUES (NEW.geoname_id, COALESCE(current_import, NEW.import_id), op, COALESCE(diff, '{}'::JSONB));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_location_history ON locations;
+CREATE TRIGGER trg_location_history
+    AFTER INSERT OR UPDATE OR DELETE ON locations
+    FOR EACH ROW EXECUTE FUNCTION record_location_history();
@@ -0,0 +1,3 @@
+-- 删除空间索引和geography列（保留PostGIS扩展，可能被其他对象使用）
+DROP INDEX IF EXISTS idx_locations_geog;
+ALTER TABLE locations DROP COLUMN IF EXISTS geog;
@@ -0,0 +1,23 @@
+-- 可选：PostGIS 可用时添加 geography 列和 GiST 空间索引；不可用时跳过，空间查询回退到 haversine 计算。
+-- geog 为生成列，由 latitude/longitude 自动计算，任何写入（包括 SaveLocations 的 upsert）都会保持同步。
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'postgis') THEN
+        RAISE NOTICE 'PostGIS 不可用，跳过 geography 列';
+        RETURN;
+    END IF;
+
+    BEGIN
+        CREATE EXTENSION IF NOT EXISTS postgis;
+    EXCEPTION WHEN insufficient_privilege THEN
+        RAISE NOTICE '没有创建 PostGIS 扩展的权限，跳过 geography 列';
+        RETURN;
+    END;
+
+    EXECUTE 'ALTER TABLE locations ADD COLUMN IF NOT EXISTS geog geography(Point, 4326)
+        GENERATED ALWAYS AS (ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography) STORED';
+
+    -- 创建索引
+    EXECUTE 'CREATE INDEX IF NOT EXISTS idx_locations_geog ON locations USING GIST (geog)';
+END
+$$;
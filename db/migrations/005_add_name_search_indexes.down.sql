@@ -0,0 +1,6 @@
+-- 删除名称搜索索引（保留扩展，可能被其他对象使用）
+DROP INDEX IF EXISTS idx_locations_name_fts;
+DROP INDEX IF EXISTS idx_locations_alternate_names_trgm;
+DROP INDEX IF EXISTS idx_locations_ascii_name_trgm;
+DROP INDEX IF EXISTS idx_locations_name_trgm;
+DROP FUNCTION IF EXISTS geonames_unaccent(text);
@@ -0,0 +1,61 @@
+-- 名称模糊搜索：pg_trgm 提供三元组相似度，unaccent 去除变音符号（München -> Munchen）。
+-- 两个扩展均为可选：不可用或没有创建权限时跳过，搜索回退到不去除变音符号的全文匹配和 ILIKE 子串匹配。
+-- 事后安装扩展需回滚并重新应用本迁移（migrate to 4 后 migrate up），以重建依赖 geonames_unaccent 的索引。
+DO $migration$
+DECLARE
+    has_trgm boolean := false;
+    has_unaccent boolean := false;
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'pg_trgm') THEN
+        BEGIN
+            CREATE EXTENSION IF NOT EXISTS pg_trgm;
+            has_trgm := true;
+        EXCEPTION WHEN insufficient_privilege THEN
+            RAISE NOTICE '没有创建 pg_trgm 扩展的权限，跳过三元组索引';
+        END;
+    ELSE
+        RAISE NOTICE 'pg_trgm 不可用，跳过三元组索引';
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'unaccent') THEN
+        BEGIN
+            CREATE EXTENSION IF NOT EXISTS unaccent WITH SCHEMA public;
+            has_unaccent := true;
+        EXCEPTION WHEN insufficient_privilege THEN
+            RAISE NOTICE '没有创建 unaccent 扩展的权限，搜索不去除变音符号';
+        END;
+    ELSE
+        RAISE NOTICE 'unaccent 不可用，搜索不去除变音符号';
+    END IF;
+
+    -- unaccent() 依赖 search_path 查找词典，只是 STABLE，不能用于索引表达式；
+    -- 固定词典和模式后包装为 IMMUTABLE 函数。没有 unaccent 时原样返回，查询和索引表达式保持不变
+    IF has_unaccent THEN
+        EXECUTE $fn$
+            CREATE OR REPLACE FUNCTION geonames_unaccent(text) RETURNS text
+                LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+                AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$
+        $fn$;
+    ELSE
+        EXECUTE $fn$
+            CREATE OR REPLACE FUNCTION geonames_unaccent(text) RETURNS text
+                LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+                AS $$ SELECT $1 $$
+        $fn$;
+    END IF;
+
+    -- 三元组索引，查询时必须使用相同的表达式才能命中
+    IF has_trgm THEN
+        EXECUTE 'CREATE INDEX IF NOT EXISTS idx_locations_name_trgm
+            ON locations USING GIN (lower(geonames_unaccent(name)) gin_trgm_ops)';
+        EXECUTE 'CREATE INDEX IF NOT EXISTS idx_locations_ascii_name_trgm
+            ON locations USING GIN (lower(ascii_name) gin_trgm_ops)';
+        EXECUTE 'CREATE INDEX IF NOT EXISTS idx_locations_alternate_names_trgm
+            ON locations USING GIN (lower(geonames_unaccent(alternate_names)) gin_trgm_ops)';
+    END IF;
+END
+$migration$;
+
+-- 全文索引，用于按完整单词匹配名称和别名
+CREATE INDEX IF NOT EXISTS idx_locations_name_fts
+    ON locations USING GIN (to_tsvector('simple', geonames_unaccent(name || ' ' || coalesce(alternate_names, ''))));
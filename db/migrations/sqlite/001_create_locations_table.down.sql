@@ -0,0 +1,3 @@
+-- 删除locations表和imports表
+DROP TABLE IF EXISTS imports;
+DROP TABLE IF EXISTS locations;
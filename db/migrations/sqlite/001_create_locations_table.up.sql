@@ -0,0 +1,39 @@
+-- 创建locations表，字段与PostgreSQL版本一致
+CREATE TABLE IF NOT EXISTS locations (
+    geoname_id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    ascii_name TEXT,
+    alternate_names TEXT,
+    latitude REAL NOT NULL,
+    longitude REAL NOT NULL,
+    feature_class TEXT,
+    feature_code TEXT,
+    country_code TEXT,
+    admin1_code TEXT,
+    admin2_code TEXT,
+    population INTEGER,
+    elevation INTEGER,
+    timezone TEXT,
+    modification_date TEXT,
+    import_id INTEGER,
+    deleted_at TIMESTAMP
+);
+
+-- 创建imports表，记录每次导入
+CREATE TABLE IF NOT EXISTS imports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    full_sync BOOLEAN NOT NULL DEFAULT FALSE,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    rows_written INTEGER,
+    rows_deleted INTEGER
+);
+
+-- 创建索引
+CREATE INDEX IF NOT EXISTS idx_locations_country_code ON locations(country_code);
+CREATE INDEX IF NOT EXISTS idx_locations_feature_class ON locations(feature_class);
+CREATE INDEX IF NOT EXISTS idx_locations_admin1_code ON locations(admin1_code);
+CREATE INDEX IF NOT EXISTS idx_locations_admin2_code ON locations(admin2_code);
+CREATE INDEX IF NOT EXISTS idx_locations_timezone ON locations(timezone);
+CREATE INDEX IF NOT EXISTS idx_locations_import_id ON locations(import_id);
+CREATE INDEX IF NOT EXISTS idx_locations_deleted_at ON locations(deleted_at);
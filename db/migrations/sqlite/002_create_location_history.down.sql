@@ -0,0 +1,6 @@
+-- 删除历史触发器和表
+DROP TRIGGER IF EXISTS trg_location_history_delete;
+DROP TRIGGER IF EXISTS trg_location_history_update;
+DROP TRIGGER IF EXISTS trg_location_history_insert;
+DROP TABLE IF EXISTS import_context;
+DROP TABLE IF EXISTS location_history;
@@ -0,0 +1,102 @@
+-- 创建location_history表，记录每个地点每次导入中的字段变更
+CREATE TABLE IF NOT EXISTS location_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    geoname_id INTEGER NOT NULL,
+    import_id INTEGER,
+    operation TEXT NOT NULL,
+    changes TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- 创建索引
+CREATE INDEX IF NOT EXISTS idx_location_history_geoname_id ON location_history(geoname_id, changed_at);
+
+-- SQLite 没有会话变量，写入事务开始时把当前导入ID写入此表（仅一行），结束前清空，
+-- 触发器据此记录变更来源（对应 PostgreSQL 中的 geonames.import_id）
+CREATE TABLE IF NOT EXISTS import_context (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    import_id INTEGER
+);
+
+-- 以下触发器与PostgreSQL的 record_location_history 行为一致：
+-- 变更以 {"字段": {"old": ..., "new": ...}} 形式记录，不包含 import_id 和 deleted_at
+CREATE TRIGGER IF NOT EXISTS trg_location_history_insert
+AFTER INSERT ON locations
+BEGIN
+    INSERT INTO location_history (geoname_id, import_id, operation, changes)
+    SELECT NEW.geoname_id,
+        COALESCE((SELECT import_id FROM import_context), NEW.import_id),
+        'insert',
+        json_group_object(key, json_object('old', NULL, 'new', value))
+    FROM (
+        SELECT 'geoname_id' AS key, NEW.geoname_id AS value
+        UNION ALL SELECT 'name', NEW.name
+        UNION ALL SELECT 'ascii_name', NEW.ascii_name
+        UNION ALL SELECT 'alternate_names', NEW.alternate_names
+        UNION ALL SELECT 'latitude', NEW.latitude
+        UNION ALL SELECT 'longitude', NEW.longitude
+        UNION ALL SELECT 'feature_class', NEW.feature_class
+        UNION ALL SELECT 'feature_code', NEW.feature_code
+        UNION ALL SELECT 'country_code', NEW.country_code
+        UNION ALL SELECT 'admin1_code', NEW.admin1_code
+        UNION ALL SELECT 'admin2_code', NEW.admin2_code
+        UNION ALL SELECT 'population', NEW.population
+        UNION ALL SELECT 'elevation', NEW.elevation
+        UNION ALL SELECT 'timezone', NEW.timezone
+        UNION ALL SELECT 'modification_date', NEW.modification_date
+    );
+END;
+
+-- 重新导入未变化的行只会更新import_id，不记录历史
+CREATE TRIGGER IF NOT EXISTS trg_location_history_update
+AFTER UPDATE ON locations
+WHEN (OLD.deleted_at IS NULL) <> (NEW.deleted_at IS NULL)
+    OR OLD.name IS NOT NEW.name
+    OR OLD.ascii_name IS NOT NEW.ascii_name
+    OR OLD.alternate_names IS NOT NEW.alternate_names
+    OR OLD.latitude IS NOT NEW.latitude
+    OR OLD.longitude IS NOT NEW.longitude
+    OR OLD.feature_class IS NOT NEW.feature_class
+    OR OLD.feature_code IS NOT NEW.feature_code
+    OR OLD.country_code IS NOT NEW.country_code
+    OR OLD.admin1_code IS NOT NEW.admin1_code
+    OR OLD.admin2_code IS NOT NEW.admin2_code
+    OR OLD.population IS NOT NEW.population
+    OR OLD.elevation IS NOT NEW.elevation
+    OR OLD.timezone IS NOT NEW.timezone
+    OR OLD.modification_date IS NOT NEW.modification_date
+BEGIN
+    INSERT INTO location_history (geoname_id, import_id, operation, changes)
+    SELECT NEW.geoname_id,
+        COALESCE((SELECT import_id FROM import_context), NEW.import_id),
+        CASE
+            WHEN NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN 'delete'
+            WHEN NEW.deleted_at IS NULL AND OLD.deleted_at IS NOT NULL THEN 'restore'
+            ELSE 'update'
+        END,
+        json_group_object(key, json_object('old', old_value, 'new', new_value))
+    FROM (
+        SELECT 'name' AS key, OLD.name AS old_value, NEW.name AS new_value
+        UNION ALL SELECT 'ascii_name', OLD.ascii_name, NEW.ascii_name
+        UNION ALL SELECT 'alternate_names', OLD.alternate_names, NEW.alternate_names
+        UNION ALL SELECT 'latitude', OLD.latitude, NEW.latitude
+        UNION ALL SELECT 'longitude', OLD.longitude, NEW.longitude
+        UNION ALL SELECT 'feature_class', OLD.feature_class, NEW.feature_class
+        UNION ALL SELECT 'feature_code', OLD.feature_code, NEW.feature_code
+        UNION ALL SELECT 'country_code', OLD.country_code, NEW.country_code
+        UNION ALL SELECT 'admin1_code', OLD.admin1_code, NEW.admin1_code
+        UNION ALL SELECT 'admin2_code', OLD.admin2_code, NEW.admin2_code
+        UNION ALL SELECT 'population', OLD.population, NEW.population
+        UNION ALL SELECT 'elevation', OLD.elevation, NEW.elevation
+        UNION ALL SELECT 'timezone', OLD.timezone, NEW.timezone
+        UNION ALL SELECT 'modification_date', OLD.modification_date, NEW.modification_date
+    )
+    WHERE old_value IS NOT new_value;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_location_history_delete
+AFTER DELETE ON locations
+BEGIN
+    INSERT INTO location_history (geoname_id, import_id, operation, changes)
+    VALUES (OLD.geoname_id, (SELECT import_id FROM import_context), 'delete', '{}');
+END;
@@ -0,0 +1,8 @@
+-- 删除空间索引和全文索引
+DROP TRIGGER IF EXISTS trg_locations_index_update_names;
+DROP TRIGGER IF EXISTS trg_locations_index_update_position;
+DROP TRIGGER IF EXISTS trg_locations_index_delete;
+DROP TRIGGER IF EXISTS trg_locations_index_insert;
+DROP TABLE IF EXISTS locations_trgm;
+DROP TABLE IF EXISTS locations_fts;
+DROP TABLE IF EXISTS locations_rtree;
@@ -0,0 +1,70 @@
+-- R*Tree 空间索引，每个地点存为一个点（最小值等于最大值）
+CREATE VIRTUAL TABLE IF NOT EXISTS locations_rtree USING rtree(
+    id,
+    min_lat, max_lat,
+    min_lon, max_lon
+);
+
+-- FTS5 全文索引（按单词匹配）和三元组索引（模糊匹配），内容取自 locations 表，
+-- remove_diacritics 忽略变音符号（München 与 Munchen 等价）
+CREATE VIRTUAL TABLE IF NOT EXISTS locations_fts USING fts5(
+    name, ascii_name, alternate_names,
+    content='locations', content_rowid='geoname_id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS locations_trgm USING fts5(
+    name, ascii_name, alternate_names,
+    content='locations', content_rowid='geoname_id',
+    tokenize='trigram remove_diacritics 1'
+);
+
+-- 为已有数据建立索引
+INSERT INTO locations_rtree (id, min_lat, max_lat, min_lon, max_lon)
+    SELECT geoname_id, latitude, latitude, longitude, longitude FROM locations;
+INSERT INTO locations_fts (locations_fts) VALUES ('rebuild');
+INSERT INTO locations_trgm (locations_trgm) VALUES ('rebuild');
+
+-- 触发器保持索引与 locations 表同步
+CREATE TRIGGER IF NOT EXISTS trg_locations_index_insert
+AFTER INSERT ON locations
+BEGIN
+    INSERT INTO locations_rtree (id, min_lat, max_lat, min_lon, max_lon)
+        VALUES (NEW.geoname_id, NEW.latitude, NEW.latitude, NEW.longitude, NEW.longitude);
+    INSERT INTO locations_fts (rowid, name, ascii_name, alternate_names)
+        VALUES (NEW.geoname_id, NEW.name, NEW.ascii_name, NEW.alternate_names);
+    INSERT INTO locations_trgm (rowid, name, ascii_name, alternate_names)
+        VALUES (NEW.geoname_id, NEW.name, NEW.ascii_name, NEW.alternate_names);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_locations_index_delete
+AFTER DELETE ON locations
+BEGIN
+    DELETE FROM locations_rtree WHERE id = OLD.geoname_id;
+    INSERT INTO locations_fts (locations_fts, rowid, name, ascii_name, alternate_names)
+        VALUES ('delete', OLD.geoname_id, OLD.name, OLD.ascii_name, OLD.alternate_names);
+    INSERT INTO locations_trgm (locations_trgm, rowid, name, ascii_name, alternate_names)
+        VALUES ('delete', OLD.geoname_id, OLD.name, OLD.ascii_name, OLD.alternate_names);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_locations_index_update_position
+AFTER UPDATE OF latitude, longitude ON locations
+WHEN OLD.latitude IS NOT NEW.latitude OR OLD.longitude IS NOT NEW.longitude
+BEGIN
+    UPDATE locations_rtree
+    SET min_lat = NEW.latitude, max_lat = NEW.latitude, min_lon = NEW.longitude, max_lon = NEW.longitude
+    WHERE id = NEW.geoname_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_locations_index_update_names
+AFTER UPDATE OF name, ascii_name, alternate_names ON locations
+WHEN OLD.name IS NOT NEW.name OR OLD.ascii_name IS NOT NEW.ascii_name OR OLD.alternate_names IS NOT NEW.alternate_names
+BEGIN
+    INSERT INTO locations_fts (locations_fts, rowid, name, ascii_name, alternate_names)
+        VALUES ('delete', OLD.geoname_id, OLD.name, OLD.ascii_name, OLD.alternate_names);
+    INSERT INTO locations_fts (rowid, name, ascii_name, alternate_names)
+        VALUES (NEW.geoname_id, NEW.name, NEW.ascii_name, NEW.alternate_names);
+    INSERT INTO locations_trgm (locations_trgm, rowid, name, ascii_name, alternate_names)
+        VALUES ('delete', OLD.geoname_id, OLD.name, OLD.ascii_name, OLD.alternate_names);
+    INSERT INTO locations_trgm (rowid, name, ascii_name, alternate_names)
+        VALUES (NEW.geoname_id, NEW.name, NEW.ascii_name, NEW.alternate_names);
+END;
@@ -0,0 +1,142 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/unxai/geonames-service/models"
+)
+
+// 变更类型
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// FieldChange 单个字段的新旧值
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Entry 一条位置记录的变更，按 JSONL 逐行输出
+type Entry struct {
+	Op        string                 `json:"op"`
+	GeonameID int                    `json:"geoname_id"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+}
+
+// Summary 差异统计
+type Summary struct {
+	Inserts      int            `json:"inserts"`
+	Updates      int            `json:"updates"`
+	Deletes      int            `json:"deletes"`
+	Unchanged    int            `json:"unchanged"`
+	FieldUpdates map[string]int `json:"field_updates"` // 每个字段被更新的记录数
+}
+
+// IterateFunc 按 geoname_id 升序遍历已有数据
+type IterateFunc func(fn func(models.Location) error) error
+
+// Compare 将新数据与已有数据按 geoname_id 归并比较，统计插入、更新和将被删除的记录。
+// w 不为 nil 时将每条变更以 JSONL 格式写入 w。incoming 不会被修改。
+func Compare(incoming []models.Location, iterate IterateFunc, w io.Writer) (*Summary, error) {
+	// 只对下标排序，避免复制整个数据集
+	order := make([]int, len(incoming))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return incoming[order[i]].GeonameID < incoming[order[j]].GeonameID
+	})
+
+	summary := &Summary{FieldUpdates: make(map[string]int)}
+	var enc *json.Encoder
+	if w != nil {
+		enc = json.NewEncoder(w)
+	}
+	emit := func(e Entry) error {
+		if enc == nil {
+			return nil
+		}
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("写入差异失败: %w", err)
+		}
+		return nil
+	}
+
+	next := 0
+	// flushInserts 输出所有 ID 小于 limit 的新记录
+	flushInserts := func(limit int, bounded bool) error {
+		for next < len(order) {
+			loc := incoming[order[next]]
+			if bounded && loc.GeonameID >= limit {
+				return nil
+			}
+			summary.Inserts++
+			if err := emit(Entry{Op: OpInsert, GeonameID: loc.GeonameID}); err != nil {
+				return err
+			}
+			next++
+		}
+		return nil
+	}
+
+	err := iterate(func(existing models.Location) error {
+		if err := flushInserts(existing.GeonameID, true); err != nil {
+			return err
+		}
+
+		if next >= len(order) || incoming[order[next]].GeonameID != existing.GeonameID {
+			summary.Deletes++
+			return emit(Entry{Op: OpDelete, GeonameID: existing.GeonameID})
+		}
+
+		changes := Fields(existing, incoming[order[next]])
+		next++
+		if len(changes) == 0 {
+			summary.Unchanged++
+			return nil
+		}
+
+		summary.Updates++
+		for field := range changes {
+			summary.FieldUpdates[field]++
+		}
+		return emit(Entry{Op: OpUpdate, GeonameID: existing.GeonameID, Changes: changes})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := flushInserts(0, false); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// Fields 比较两条记录，返回以 JSON 字段名为键的变更集合
+func Fields(old, new models.Location) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+
+	ov := reflect.ValueOf(old)
+	nv := reflect.ValueOf(new)
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		a := ov.Field(i).Interface()
+		b := nv.Field(i).Interface()
+		if a == b {
+			continue
+		}
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		changes[name] = FieldChange{Old: a, New: b}
+	}
+
+	return changes
+}
@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/unxai/geonames-service/models"
+)
+
+// iterateOver 按给定顺序遍历 existing，existing 须已按 geoname_id 升序排列
+func iterateOver(existing []models.Location) IterateFunc {
+	return func(fn func(models.Location) error) error {
+		for _, loc := range existing {
+			if err := fn(loc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func loc(id int, name string, population int) models.Location {
+	return models.Location{GeonameID: id, Name: name, ASCII_Name: name, CountryCode: "XX", Population: population}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []models.Location
+		incoming []models.Location
+		want     Summary
+		jsonl    []string
+	}{
+		{
+			name:     "新增",
+			incoming: []models.Location{loc(2, "B", 0), loc(1, "A", 0)},
+			want:     Summary{Inserts: 2, FieldUpdates: map[string]int{}},
+			jsonl: []string{
+				`{"op":"insert","geoname_id":1}`,
+				`{"op":"insert","geoname_id":2}`,
+			},
+		},
+		{
+			name:     "更新",
+			existing: []models.Location{loc(1, "A", 100), loc(2, "B", 5)},
+			incoming: []models.Location{loc(1, "A", 200), {GeonameID: 2, Name: "B2", ASCII_Name: "B2", CountryCode: "XX", Population: 6}},
+			want:     Summary{Updates: 2, FieldUpdates: map[string]int{"population": 2, "name": 1, "ascii_name": 1}},
+			jsonl: []string{
+				`{"op":"update","geoname_id":1,"changes":{"population":{"old":100,"new":200}}}`,
+				`{"op":"update","geoname_id":2,"changes":{"ascii_name":{"old":"B","new":"B2"},"name":{"old":"B","new":"B2"},"population":{"old":5,"new":6}}}`,
+			},
+		},
+		{
+			name:     "删除",
+			existing: []models.Location{loc(1, "A", 0), loc(2, "B", 0)},
+			incoming: []models.Location{loc(2, "B", 0)},
+			want:     Summary{Deletes: 1, Unchanged: 1, FieldUpdates: map[string]int{}},
+			jsonl:    []string{`{"op":"delete","geoname_id":1}`},
+		},
+		{
+			name:     "未变化",
+			existing: []models.Location{loc(1, "A", 0)},
+			incoming: []models.Location{loc(1, "A", 0)},
+			want:     Summary{Unchanged: 1, FieldUpdates: map[string]int{}},
+		},
+		{
+			name:     "交错归并",
+			existing: []models.Location{loc(2, "B", 0), loc(4, "D", 0), loc(6, "F", 0)},
+			incoming: []models.Location{loc(7, "G", 0), loc(4, "D", 1), loc(1, "A", 0), loc(5, "E", 0), loc(6, "F", 0)},
+			want:     Summary{Inserts: 3, Updates: 1, Deletes: 1, Unchanged: 1, FieldUpdates: map[string]int{"population": 1}},
+			jsonl: []string{
+				`{"op":"insert","geoname_id":1}`,
+				`{"op":"delete","geoname_id":2}`,
+				`{"op":"update","geoname_id":4,"changes":{"population":{"old":0,"new":1}}}`,
+				`{"op":"insert","geoname_id":5}`,
+				`{"op":"insert","geoname_id":7}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incoming := append([]models.Location(nil), tt.incoming...)
+			var out bytes.Buffer
+			summary, err := Compare(incoming, iterateOver(tt.existing), &out)
+			if err != nil {
+				t.Fatalf("比较失败: %v", err)
+			}
+			if !reflect.DeepEqual(*summary, tt.want) {
+				t.Errorf("统计 = %+v，期望 %+v", *summary, tt.want)
+			}
+
+			var lines []string
+			if s := strings.TrimSuffix(out.String(), "\n"); s != "" {
+				lines = strings.Split(s, "\n")
+			}
+			if !reflect.DeepEqual(lines, tt.jsonl) {
+				t.Errorf("JSONL 输出 =\n%s\n期望\n%s", strings.Join(lines, "\n"), strings.Join(tt.jsonl, "\n"))
+			}
+			if !reflect.DeepEqual(incoming, tt.incoming) {
+				t.Errorf("Compare 修改了传入的数据")
+			}
+
+			// 不输出差异时统计相同
+			summary, err = Compare(incoming, iterateOver(tt.existing), nil)
+			if err != nil || !reflect.DeepEqual(*summary, tt.want) {
+				t.Errorf("w 为 nil 时统计 = %+v, %v，期望 %+v", summary, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestCompareIterateError 遍历已有数据出错时返回该错误
+func TestCompareIterateError(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := Compare([]models.Location{loc(1, "A", 0)}, func(fn func(models.Location) error) error {
+		return boom
+	}, nil)
+	if !errors.Is(err, boom) {
+		t.Fatalf("err = %v，期望 %v", err, boom)
+	}
+}
@@ -0,0 +1,174 @@
+// Package health 提供存活（/healthz）和就绪（/readyz）检查，供编排系统探测服务状态。
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/unxai/geonames-service/api/respond"
+	"github.com/unxai/geonames-service/db"
+	"go.uber.org/zap"
+)
+
+// 检查结果
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check 单项检查的结果
+type Check struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report 就绪检查的响应体，checks 中任一项失败时 status 为 fail
+type Report struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
+
+// Checker 检查服务是否可以接收流量：数据库可达、迁移不低于服务所需的版本、首次导入已完成，且未在关闭中
+type Checker struct {
+	db       *sql.DB
+	migrator *db.Migrator
+	expected int64 // 迁移来源中的最新版本
+	timeout  time.Duration
+	log      *zap.Logger
+
+	shuttingDown atomic.Bool
+}
+
+// NewChecker 创建就绪检查器，timeout 为一次检查中所有数据库查询的总超时
+func NewChecker(conn *sql.DB, migrator *db.Migrator, timeout time.Duration, log *zap.Logger) (*Checker, error) {
+	expected, err := migrator.LatestVersion()
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移版本失败: %w", err)
+	}
+	return &Checker{db: conn, migrator: migrator, expected: expected, timeout: timeout, log: log}, nil
+}
+
+// SetShuttingDown 标记服务正在关闭，之后就绪检查始终失败，负载均衡据此停止转发新请求
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Liveness 进程存活即返回 200，不检查依赖，避免数据库故障时服务被反复重启
+func (c *Checker) Liveness(w http.ResponseWriter, r *http.Request) {
+	respond.JSON(w, http.StatusOK, Report{Status: StatusOK, Checks: map[string]Check{}})
+}
+
+// Readiness 执行所有检查，全部通过返回 200，否则返回 503，响应体中包含每一项的结果
+func (c *Checker) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respond.JSON(w, status, report)
+}
+
+// Check 依次检查关闭状态、数据库连接、迁移版本、数据和导入状态。数据库不可达时跳过后三项。
+// 与 API 的错误响应一致，原始错误只写入日志
+func (c *Checker) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	checks := make(map[string]Check)
+	if c.shuttingDown.Load() {
+		checks["shutdown"] = fail("服务正在关闭")
+	} else {
+		checks["shutdown"] = ok("")
+	}
+
+	if err := c.db.PingContext(ctx); err != nil {
+		c.log.Warn("就绪检查失败：数据库不可达", zap.Error(err))
+		checks["database"] = fail("数据库不可达")
+		checks["migrations"] = fail("数据库不可达，未检查")
+		checks["data"] = fail("数据库不可达，未检查")
+		checks["import"] = fail("数据库不可达，未检查")
+		return newReport(checks)
+	}
+	checks["database"] = ok("")
+	checks["migrations"] = c.checkMigrations(ctx)
+	checks["data"] = c.checkData(ctx)
+	checks["import"] = c.checkImport(ctx)
+
+	return newReport(checks)
+}
+
+// checkMigrations 数据库的迁移版本不能低于迁移来源中的最新版本。
+// 版本更高说明滚动发布中更新的服务版本已执行迁移，迁移只增加对象，旧版本仍可读取，不视为失败
+func (c *Checker) checkMigrations(ctx context.Context) Check {
+	version, err := c.migrator.Version(ctx)
+	if err != nil {
+		c.log.Warn("就绪检查失败：查询迁移版本失败", zap.Error(err))
+		return fail("查询迁移版本失败")
+	}
+	if version < c.expected {
+		return fail(fmt.Sprintf("迁移版本为 %d，期望 %d", version, c.expected))
+	}
+	if version > c.expected {
+		return ok(fmt.Sprintf("版本 %d，高于服务所需的 %d", version, c.expected))
+	}
+	return ok(fmt.Sprintf("版本 %d", version))
+}
+
+// checkData 至少有一条未删除的位置数据，空库说明尚未完成首次导入
+func (c *Checker) checkData(ctx context.Context) Check {
+	var exists bool
+	err := c.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM locations WHERE deleted_at IS NULL)").Scan(&exists)
+	if err != nil {
+		c.log.Warn("就绪检查失败：查询位置数据失败", zap.Error(err))
+		return fail("查询位置数据失败")
+	}
+	if !exists {
+		return fail("尚未导入位置数据")
+	}
+	return ok("")
+}
+
+// checkImport 首次导入须已完成，否则 data 检查通过时库中也可能只有前几批数据。
+// 之后的导入按批次原地更新、过期记录在一个事务中删除，查询可能同时看到新旧版本的行但不会缺少数据，
+// 而所有实例共用一个数据库，导入期间全部摘除会造成中断，因此不视为失败。
+// 没有任何导入记录但有数据时（导入记录功能之前加载的数据）同样视为完成
+func (c *Checker) checkImport(ctx context.Context) Check {
+	var finished, running bool
+	err := c.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM imports WHERE finished_at IS NOT NULL),
+			EXISTS (SELECT 1 FROM imports WHERE finished_at IS NULL)`).Scan(&finished, &running)
+	if err != nil {
+		c.log.Warn("就绪检查失败：查询导入记录失败", zap.Error(err))
+		return fail("查询导入记录失败")
+	}
+	if running && !finished {
+		return fail("首次导入尚未完成")
+	}
+	if running {
+		return ok("有未完成的导入，数据按批次原地更新")
+	}
+	return ok("")
+}
+
+func newReport(checks map[string]Check) Report {
+	report := Report{Status: StatusOK, Checks: checks}
+	for _, check := range checks {
+		if check.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func ok(message string) Check {
+	return Check{Status: StatusOK, Message: message}
+}
+
+func fail(message string) Check {
+	return Check{Status: StatusFail, Message: message}
+}
@@ -11,13 +11,11 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var Logger *zap.Logger
-
-// InitLogger 初始化日志配置
-func InitLogger(level string, logPath string) error {
+// New 按配置创建日志实例
+func New(level string, logPath string) (*zap.Logger, error) {
 	// 创建日志目录
 	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
-		return fmt.Errorf("创建日志目录失败: %v", err)
+		return nil, fmt.Errorf("创建日志目录失败: %v", err)
 	}
 
 	// 设置日志级别
@@ -66,14 +64,14 @@ func InitLogger(level string, logPath string) error {
 	)
 
 	// 创建logger
-	Logger = zap.New(core, zap.AddCaller())
-	defer Logger.Sync()
+	log := zap.New(core, zap.AddCaller())
+	defer log.Sync()
 
-	Logger.Info("日志系统初始化成功",
+	log.Info("日志系统初始化成功",
 		zap.String("level", level),
 		zap.String("path", logPath),
 		zap.Time("time", time.Now()),
 	)
 
-	return nil
+	return log, nil
 }
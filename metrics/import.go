@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Import 一次导入的指标。CLI 进程在导入结束后即退出，无法被抓取，
+// 因此指标写入 node_exporter 的 textfile 目录或推送到 Pushgateway
+type Import struct {
+	registry     *prometheus.Registry
+	rowsParsed   prometheus.Gauge
+	rowsRejected prometheus.Gauge
+	rowsWritten  prometheus.Gauge
+	rowsDeleted  prometheus.Gauge
+	duration     prometheus.Gauge
+	lastRun      prometheus.Gauge
+	success      prometheus.Gauge
+	lastSuccess  prometheus.Gauge // 仅在导入成功时登记，失败时保留上一次成功的时间
+	start        time.Time
+	succeeded    bool
+}
+
+// lastSuccessName 上次成功导入时间的指标名称，失败时从已有的 textfile 中读取
+const lastSuccessName = namespace + "_import_last_success_timestamp_seconds"
+
+// NewImport 创建导入指标并开始计时
+func NewImport() *Import {
+	gauge := func(name, help string) prometheus.Gauge {
+		return prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Subsystem: "import", Name: name, Help: help})
+	}
+	m := &Import{
+		registry:     prometheus.NewRegistry(),
+		rowsParsed:   gauge("rows_parsed", "最近一次导入解析成功的行数"),
+		rowsRejected: gauge("rows_rejected", "最近一次导入因格式错误被丢弃的行数"),
+		rowsWritten:  gauge("rows_written", "最近一次导入写入数据库的行数"),
+		rowsDeleted:  gauge("rows_deleted", "最近一次全量同步软删除的行数"),
+		duration:     gauge("duration_seconds", "最近一次导入的耗时（秒）"),
+		lastRun:      gauge("last_run_timestamp_seconds", "最近一次导入结束的时间"),
+		success:      gauge("success", "最近一次导入是否成功（1 成功，0 失败）"),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: lastSuccessName,
+			Help: "最近一次成功导入结束的时间",
+		}),
+		start: time.Now(),
+	}
+	m.registry.MustRegister(m.rowsParsed, m.rowsRejected, m.rowsWritten, m.rowsDeleted, m.duration, m.lastRun, m.success)
+	return m
+}
+
+// SetParsed 记录解析结果
+func (m *Import) SetParsed(parsed, rejected int) {
+	m.rowsParsed.Set(float64(parsed))
+	m.rowsRejected.Set(float64(rejected))
+}
+
+// SetWritten 记录写入和删除的行数
+func (m *Import) SetWritten(written, deleted int64) {
+	m.rowsWritten.Set(float64(written))
+	m.rowsDeleted.Set(float64(deleted))
+}
+
+// Finish 记录导入耗时和结果，err 为 nil 表示成功
+func (m *Import) Finish(err error) {
+	now := time.Now()
+	m.duration.Set(now.Sub(m.start).Seconds())
+	m.lastRun.Set(float64(now.Unix()))
+	if err != nil {
+		m.success.Set(0)
+		return
+	}
+	m.success.Set(1)
+	m.lastSuccess.Set(float64(now.Unix()))
+	m.registry.MustRegister(m.lastSuccess)
+	m.succeeded = true
+}
+
+// WriteTextfile 将指标原子地写入 node_exporter textfile collector 读取的文件（*.prom）。
+// 导入失败时沿用文件中已有的上次成功时间
+func (m *Import) WriteTextfile(path string) error {
+	if !m.succeeded {
+		if value, ok := readGauge(path, lastSuccessName); ok {
+			m.lastSuccess.Set(value)
+			m.registry.Register(m.lastSuccess)
+		}
+	}
+	if err := prometheus.WriteToTextfile(path, m.registry); err != nil {
+		return fmt.Errorf("写入指标文件失败: %w", err)
+	}
+	return nil
+}
+
+// Push 将指标推送到 Pushgateway。成功时替换该 job 下的全部指标；
+// 失败时只更新本次上报的指标，保留 Pushgateway 中上次成功的时间
+func (m *Import) Push(url, job string) error {
+	pusher := push.New(url, job).Gatherer(m.registry)
+	var err error
+	if m.succeeded {
+		err = pusher.Push()
+	} else {
+		err = pusher.Add()
+	}
+	if err != nil {
+		return fmt.Errorf("推送指标失败: %w", err)
+	}
+	return nil
+}
+
+// readGauge 从已有的指标文件中读取 gauge 的值，文件不存在或没有该指标时返回 false
+func readGauge(path, name string) (float64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		return 0, false
+	}
+	family, ok := families[name]
+	if !ok || len(family.GetMetric()) == 0 || family.GetMetric()[0].GetGauge() == nil {
+		return 0, false
+	}
+	return family.GetMetric()[0].GetGauge().GetValue(), true
+}
@@ -0,0 +1,88 @@
+// Package metrics 定义 HTTP 服务和导入命令的 Prometheus 指标。
+// 每个应用实例使用独立的注册表，同一进程中的多个实例互不影响。
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/unxai/geonames-service/db"
+	"github.com/unxai/geonames-service/storage"
+)
+
+// namespace 所有指标名称的前缀
+const namespace = "geonames"
+
+// unmatchedRoute 未匹配任何路由的请求使用的 route 标签，避免按原始路径产生大量标签值
+const unmatchedRoute = "unmatched"
+
+// Server HTTP 服务的指标：请求数和耗时、连接池状态、存储查询耗时，以及 Go 运行时和进程指标
+type Server struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+}
+
+// NewServer 创建指标注册表并登记 cluster 中每个连接池的 sql.DBStats
+func NewServer(cluster *db.Cluster) *Server {
+	m := &Server{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP 请求数，按方法、路由模板和状态码统计",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP 请求耗时（秒），按方法和路由模板统计",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "query_duration_seconds",
+			Help:      "存储查询耗时（秒），按存储方法和结果（ok/error）统计",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+	)
+	for name, pool := range cluster.Pools() {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(pool, name))
+	}
+
+	return m
+}
+
+// Handler 返回 /metrics 的处理器
+func (m *Server) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest 记录一次 HTTP 请求，签名与 middleware.Observer 一致
+func (m *Server) ObserveRequest(r *http.Request, route string, status int, bytes int64, latency time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	m.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(r.Method, route).Observe(latency.Seconds())
+}
+
+// Storage 返回记录每个方法耗时的存储
+func (m *Server) Storage(next storage.Storage) storage.Storage {
+	return &instrumentedStorage{next: next, duration: m.queryDuration}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/storage"
+)
+
+// instrumentedStorage 记录每次调用耗时的 storage.Storage 装饰器
+type instrumentedStorage struct {
+	next     storage.Storage
+	duration *prometheus.HistogramVec
+}
+
+// observe 在方法返回时调用，err 指向方法的命名返回值
+func (s *instrumentedStorage) observe(method string, start time.Time, err *error) {
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	s.duration.WithLabelValues(method, result).Observe(time.Since(start).Seconds())
+}
+
+func (s *instrumentedStorage) StartImport(ctx context.Context, fullSync bool) (id int64, err error) {
+	defer s.observe("StartImport", time.Now(), &err)
+	return s.next.StartImport(ctx, fullSync)
+}
+
+func (s *instrumentedStorage) SaveLocations(ctx context.Context, importID int64, locations []models.Location) (err error) {
+	defer s.observe("SaveLocations", time.Now(), &err)
+	return s.next.SaveLocations(ctx, importID, locations)
+}
+
+func (s *instrumentedStorage) DeleteStaleLocations(ctx context.Context, importID int64, maxDelete int) (n int64, err error) {
+	defer s.observe("DeleteStaleLocations", time.Now(), &err)
+	return s.next.DeleteStaleLocations(ctx, importID, maxDelete)
+}
+
+func (s *instrumentedStorage) FinishImport(ctx context.Context, importID int64, written, deleted int64) (err error) {
+	defer s.observe("FinishImport", time.Now(), &err)
+	return s.next.FinishImport(ctx, importID, written, deleted)
+}
+
+func (s *instrumentedStorage) IterateLocations(ctx context.Context, fn func(models.Location) error) (err error) {
+	defer s.observe("IterateLocations", time.Now(), &err)
+	return s.next.IterateLocations(ctx, fn)
+}
+
+func (s *instrumentedStorage) ListLocations(ctx context.Context, query storage.ListQuery) (locations []models.Location, err error) {
+	defer s.observe("ListLocations", time.Now(), &err)
+	return s.next.ListLocations(ctx, query)
+}
+
+func (s *instrumentedStorage) CountLocations(ctx context.Context, countryCode string) (n int64, err error) {
+	defer s.observe("CountLocations", time.Now(), &err)
+	return s.next.CountLocations(ctx, countryCode)
+}
+
+func (s *instrumentedStorage) NearbyLocations(ctx context.Context, lat, lon, radius float64, limit int) (locations []models.LocationDistance, err error) {
+	defer s.observe("NearbyLocations", time.Now(), &err)
+	return s.next.NearbyLocations(ctx, lat, lon, radius, limit)
+}
+
+func (s *instrumentedStorage) SearchLocations(ctx context.Context, query string, fuzzy bool, limit int) (locations []models.LocationMatch, err error) {
+	defer s.observe("SearchLocations", time.Now(), &err)
+	return s.next.SearchLocations(ctx, query, fuzzy, limit)
+}
+
+func (s *instrumentedStorage) GetLocationHistory(ctx context.Context, geonameID int64) (history []models.LocationHistory, err error) {
+	defer s.observe("GetLocationHistory", time.Now(), &err)
+	return s.next.GetLocationHistory(ctx, geonameID)
+}
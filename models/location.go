@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Location struct {
 	GeonameID        int     `json:"geoname_id" db:"geoname_id"`
 	Name             string  `json:"name" db:"name"`
@@ -17,3 +22,23 @@ type Location struct {
 	TimeZone         string  `json:"timezone" db:"timezone"`
 	ModificationDate string  `json:"modification_date" db:"modification_date"`
 }
+
+// LocationDistance 附带到查询点距离的地点
+type LocationDistance struct {
+	Location
+	Distance float64 `json:"distance_m" db:"distance_m"` // 到查询点的距离（米）
+}
+
+// LocationMatch 附带匹配得分的名称搜索结果
+type LocationMatch struct {
+	Location
+	Score float64 `json:"score" db:"score"` // 匹配得分，越大越相关
+}
+
+// LocationHistory 地点的一次变更记录
+type LocationHistory struct {
+	ImportID  *int64          `json:"import_id" db:"import_id"`
+	Operation string          `json:"operation" db:"operation"`
+	Changes   json.RawMessage `json:"changes" db:"changes"`
+	ChangedAt time.Time       `json:"changed_at" db:"changed_at"`
+}
@@ -0,0 +1,283 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Unit 决定进度数值的显示格式
+type Unit int
+
+const (
+	UnitCount Unit = iota // 按条数显示
+	UnitBytes             // 按字节数显示
+)
+
+const (
+	renderInterval = 200 * time.Millisecond // 终端进度条刷新间隔
+	reportInterval = 5 * time.Second        // 日志事件及非终端输出间隔
+	barWidth       = 30                     // 进度条宽度（字符数）
+)
+
+// Bar 跟踪单个导入阶段（下载、解析、写入）的进度，
+// 在终端上渲染进度条并定期输出结构化日志事件。
+// 所有方法对 nil 接收者安全，调用方无需判断是否启用了进度报告。
+type Bar struct {
+	stage     string
+	unit      Unit
+	itemLabel string
+
+	total   atomic.Int64
+	current atomic.Int64
+	items   atomic.Int64
+
+	start time.Time
+	out   io.Writer
+	tty   bool
+	log   *zap.Logger
+
+	done     chan struct{}
+	wg       sync.WaitGroup
+	finished sync.Once
+}
+
+// Option 配置进度条的可选行为
+type Option func(*Bar)
+
+// WithItems 在主计数之外附加一个条目计数（如解析阶段按字节计算进度、同时统计行数）
+func WithItems(label string) Option {
+	return func(b *Bar) {
+		b.itemLabel = label
+	}
+}
+
+// Start 创建并启动一个进度条，total 为 0 表示总量未知，结构化进度事件写入 log
+func Start(log *zap.Logger, stage string, unit Unit, total int64, opts ...Option) *Bar {
+	b := &Bar{
+		stage: stage,
+		unit:  unit,
+		start: time.Now(),
+		out:   os.Stderr,
+		tty:   isTerminal(os.Stderr),
+		log:   log,
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.total.Store(total)
+
+	b.log.Info("阶段开始",
+		zap.String("stage", stage),
+		zap.Int64("total", total),
+	)
+
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+// Add 增加已完成的数量
+func (b *Bar) Add(n int64) {
+	if b == nil {
+		return
+	}
+	b.current.Add(n)
+}
+
+// AddItems 增加附加条目计数
+func (b *Bar) AddItems(n int64) {
+	if b == nil {
+		return
+	}
+	b.items.Add(n)
+}
+
+// SetTotal 更新总量（例如在得知 Content-Length 之后）
+func (b *Bar) SetTotal(n int64) {
+	if b == nil {
+		return
+	}
+	b.total.Store(n)
+}
+
+// Reader 包装 r，读取的字节数会自动计入进度
+func (b *Bar) Reader(r io.Reader) io.Reader {
+	if b == nil {
+		return r
+	}
+	return &countingReader{r: r, bar: b}
+}
+
+// Finish 停止刷新，输出最终状态和汇总日志，可重复调用
+func (b *Bar) Finish() {
+	if b == nil {
+		return
+	}
+	b.finished.Do(func() {
+		close(b.done)
+		b.wg.Wait()
+
+		s := b.snapshot()
+		if b.tty {
+			fmt.Fprintf(b.out, "\r%s\n", b.format(s))
+		} else {
+			fmt.Fprintln(b.out, b.format(s))
+		}
+		b.log.Info("阶段完成", b.fields(s)...)
+	})
+}
+
+func (b *Bar) loop() {
+	defer b.wg.Done()
+
+	render := time.NewTicker(renderInterval)
+	defer render.Stop()
+	report := time.NewTicker(reportInterval)
+	defer report.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-render.C:
+			if b.tty {
+				fmt.Fprintf(b.out, "\r%s", b.format(b.snapshot()))
+			}
+		case <-report.C:
+			s := b.snapshot()
+			if !b.tty {
+				fmt.Fprintln(b.out, b.format(s))
+			}
+			b.log.Info("导入进度", b.fields(s)...)
+		}
+	}
+}
+
+// snapshot 某一时刻的进度状态
+type snapshot struct {
+	current int64
+	total   int64
+	items   int64
+	elapsed time.Duration
+	rate    float64       // 每秒完成量
+	eta     time.Duration // 总量未知时为 -1
+}
+
+func (b *Bar) snapshot() snapshot {
+	s := snapshot{
+		current: b.current.Load(),
+		total:   b.total.Load(),
+		items:   b.items.Load(),
+		elapsed: time.Since(b.start),
+		eta:     -1,
+	}
+	if secs := s.elapsed.Seconds(); secs > 0 {
+		s.rate = float64(s.current) / secs
+	}
+	if s.total > 0 && s.rate > 0 {
+		remaining := s.total - s.current
+		if remaining < 0 {
+			remaining = 0
+		}
+		s.eta = time.Duration(float64(remaining) / s.rate * float64(time.Second))
+	}
+	return s
+}
+
+func (b *Bar) fields(s snapshot) []zap.Field {
+	fields := []zap.Field{
+		zap.String("stage", b.stage),
+		zap.Int64("current", s.current),
+		zap.Int64("total", s.total),
+		zap.Float64("rate_per_sec", s.rate),
+		zap.Duration("elapsed", s.elapsed),
+	}
+	if b.itemLabel != "" {
+		fields = append(fields, zap.Int64("items", s.items))
+	}
+	if s.eta >= 0 {
+		fields = append(fields, zap.Duration("eta", s.eta))
+	}
+	return fields
+}
+
+func (b *Bar) format(s snapshot) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-6s", b.stage)
+
+	if s.total > 0 {
+		ratio := float64(s.current) / float64(s.total)
+		if ratio > 1 {
+			ratio = 1
+		}
+		filled := int(ratio * barWidth)
+		sb.WriteString(" [")
+		sb.WriteString(strings.Repeat("=", filled))
+		if filled < barWidth {
+			sb.WriteString(">")
+			sb.WriteString(strings.Repeat(" ", barWidth-filled-1))
+		}
+		fmt.Fprintf(&sb, "] %5.1f%%  %s/%s", ratio*100, b.amount(s.current), b.amount(s.total))
+	} else {
+		fmt.Fprintf(&sb, " %s", b.amount(s.current))
+	}
+
+	if b.itemLabel != "" {
+		fmt.Fprintf(&sb, "  %d %s", s.items, b.itemLabel)
+	}
+	fmt.Fprintf(&sb, "  %s/s", b.amount(int64(s.rate)))
+	if s.eta >= 0 {
+		fmt.Fprintf(&sb, "  ETA %s", s.eta.Round(time.Second))
+	}
+	return sb.String()
+}
+
+func (b *Bar) amount(n int64) string {
+	if b.unit == UnitBytes {
+		return formatBytes(n)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// formatBytes 将字节数格式化为便于阅读的形式
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isTerminal 判断输出是否为交互式终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// countingReader 统计经过的字节数
+type countingReader struct {
+	r   io.Reader
+	bar *Bar
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.bar.Add(int64(n))
+	return n, err
+}
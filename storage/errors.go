@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+)
+
+// 存储错误的类别，由 Classify 从驱动错误归类得到，供 HTTP 层映射为对应的状态码
+var (
+	ErrNotFound     = errors.New("记录不存在")
+	ErrInvalidInput = errors.New("查询参数无效")
+	ErrUnavailable  = errors.New("数据库暂不可用")
+	ErrTimeout      = errors.New("查询超时")
+	ErrCanceled     = errors.New("查询已取消")
+)
+
+// SQLite 的错误码：繁忙和锁定在写入事务占用数据库时读查询可能遇到，中断由 context 取消查询引起
+const (
+	sqliteBusy      = 5
+	sqliteLocked    = 6
+	sqliteInterrupt = 9
+)
+
+// pgQueryCanceled PostgreSQL 取消语句的错误码，statement_timeout 和 context 取消都会返回
+const pgQueryCanceled = "57014"
+
+// Classify 返回 err 所属的错误类别（ErrNotFound、ErrInvalidInput、ErrUnavailable、ErrTimeout 或 ErrCanceled），
+// 无法归类时返回 nil。通过错误实现的方法判断，不依赖具体的数据库驱动。
+// 驱动中断查询时返回的错误不一定包装了 ctx.Err()，这类错误归为 ErrTimeout，调用方可结合 ctx.Err() 区分超时和取消。
+func Classify(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.Is(err, ErrInvalidInput):
+		return ErrInvalidInput
+	case errors.Is(err, ErrUnavailable), errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return ErrUnavailable
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	case errors.Is(err, ErrCanceled), errors.Is(err, context.Canceled):
+		return ErrCanceled
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrUnavailable
+	}
+
+	// PostgreSQL（lib/pq）：08 连接异常、53 资源不足、57P01-57P03 服务端关闭或正在启动；22 数据异常
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		state := pgErr.SQLState()
+		switch {
+		case strings.HasPrefix(state, "08"), strings.HasPrefix(state, "53"),
+			state == "57P01", state == "57P02", state == "57P03":
+			return ErrUnavailable
+		case strings.HasPrefix(state, "22"):
+			return ErrInvalidInput
+		case state == pgQueryCanceled:
+			return ErrTimeout
+		}
+		return nil
+	}
+
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		// 扩展错误码的低 8 位为主错误码
+		switch sqliteErr.Code() & 0xff {
+		case sqliteBusy, sqliteLocked:
+			return ErrUnavailable
+		case sqliteInterrupt:
+			return ErrTimeout
+		}
+	}
+
+	return nil
+}
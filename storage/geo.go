@@ -0,0 +1,27 @@
+package storage
+
+import "math"
+
+// EarthRadiusMeters 地球平均半径
+const EarthRadiusMeters = 6371008.8
+
+// BoundingBox 计算以 (lat, lon) 为中心、半径 radius 米的经纬度范围，用于利用普通索引预筛选。
+// 经度半宽取球面上圆的切点处的值 asin(sin(d)/cos(lat))，比 d/cos(lat) 大，高纬度时不会漏掉圆内的点。
+// 范围包含极点或跨越180度经线时不限制经度。
+func BoundingBox(lat, lon, radius float64) (minLat, maxLat, minLon, maxLon float64) {
+	d := radius / EarthRadiusMeters // 角距离（弧度）
+	deltaLat := d * 180 / math.Pi
+	minLat = math.Max(lat-deltaLat, -90)
+	maxLat = math.Min(lat+deltaLat, 90)
+
+	minLon, maxLon = -180, 180
+	if minLat > -90 && maxLat < 90 {
+		if x := math.Sin(d) / math.Cos(lat*math.Pi/180); x < 1 {
+			deltaLon := math.Asin(x) * 180 / math.Pi
+			if lon-deltaLon >= -180 && lon+deltaLon <= 180 {
+				minLon, maxLon = lon-deltaLon, lon+deltaLon
+			}
+		}
+	}
+	return minLat, maxLat, minLon, maxLon
+}
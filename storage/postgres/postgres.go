@@ -1,59 +1,119 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/unxai/geonames-service/logger"
 	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/progress"
+	"github.com/unxai/geonames-service/storage"
 	"go.uber.org/zap"
 )
 
 // PostgresStorage 实现了 Storage 接口的 PostgreSQL 存储
 type PostgresStorage struct {
-	db *sql.DB
+	db        *sql.DB
+	reader    Reader
+	batchSize int
+	log       *zap.Logger
+	progress  *progress.Bar
+
+	geographyMu sync.Mutex
+	geography   *bool // locations 表是否有 PostGIS geog 列，检测成功后缓存
+
+	trigramMu sync.Mutex
+	trigram   *bool // 是否安装了 pg_trgm 扩展，检测成功后缓存
+}
+
+// Reader 为只读查询选择连接池（如轮询只读副本）
+type Reader interface {
+	Reader() *sql.DB
+}
+
+// NewPostgresStorage 创建一个新的 PostgreSQL 存储实例，batchSize 为每个事务写入的记录数
+func NewPostgresStorage(db *sql.DB, batchSize int, log *zap.Logger) *PostgresStorage {
+	return &PostgresStorage{db: db, batchSize: batchSize, log: log}
+}
+
+// SetReader 设置 API 只读查询使用的连接池选择器，未设置时所有查询使用主库
+func (s *PostgresStorage) SetReader(reader Reader) {
+	s.reader = reader
+}
+
+// readDB 返回只读查询使用的连接池
+func (s *PostgresStorage) readDB() *sql.DB {
+	if s.reader == nil {
+		return s.db
+	}
+	return s.reader.Reader()
+}
+
+// SetProgress 设置写入进度报告，每提交一批数据后更新
+func (s *PostgresStorage) SetProgress(bar *progress.Bar) {
+	s.progress = bar
 }
 
-// NewPostgresStorage 创建一个新的 PostgreSQL 存储实例
-func NewPostgresStorage(db *sql.DB) *PostgresStorage {
-	return &PostgresStorage{db: db}
+const columnsPerRow = 16 // 每行插入的列数
+
+// StartImport 登记一次新的导入，返回导入ID
+func (s *PostgresStorage) StartImport(ctx context.Context, fullSync bool) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, "INSERT INTO imports (full_sync) VALUES ($1) RETURNING id", fullSync).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("登记导入失败: %w", err)
+	}
+	return id, nil
 }
 
-const batchSize = 1000 // 每批处理的数据量
+// FinishImport 记录导入完成时间和结果
+func (s *PostgresStorage) FinishImport(ctx context.Context, importID int64, written, deleted int64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE imports SET finished_at = NOW(), rows_written = $2, rows_deleted = $3 WHERE id = $1",
+		importID, written, deleted)
+	if err != nil {
+		return fmt.Errorf("更新导入记录失败: %w", err)
+	}
+	return nil
+}
 
-// SaveLocations 批量保存位置数据
-func (s *PostgresStorage) SaveLocations(locations []models.Location) error {
+// SaveLocations 批量保存位置数据，并将每行标记为在 importID 对应的导入中出现过
+func (s *PostgresStorage) SaveLocations(ctx context.Context, importID int64, locations []models.Location) error {
 	if len(locations) == 0 {
 		return nil
 	}
 
 	// 分批处理数据
-	for i := 0; i < len(locations); i += batchSize {
-		end := i + batchSize
+	for i := 0; i < len(locations); i += s.batchSize {
+		end := i + s.batchSize
 		if end > len(locations) {
 			end = len(locations)
 		}
 		batch := locations[i:end]
 
 		// 开启事务
-		tx, err := s.db.Begin()
+		tx, err := s.db.BeginTx(ctx, nil)
 		if err != nil {
-			logger.Logger.Error("开启事务失败", zap.Error(err))
+			s.log.Error("开启事务失败", zap.Error(err))
 			return fmt.Errorf("开启事务失败: %w", err)
 		}
 		defer tx.Rollback()
 
+		if err := setImportID(ctx, tx, importID); err != nil {
+			return err
+		}
+
 		// 构建批量插入的值占位符
 		valueStrings := make([]string, 0, len(batch))
-		valueArgs := make([]interface{}, 0, len(batch)*15)
+		valueArgs := make([]interface{}, 0, len(batch)*columnsPerRow)
 		for j, loc := range batch {
-			valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-				j*15+1, j*15+2, j*15+3, j*15+4, j*15+5, j*15+6, j*15+7, j*15+8, j*15+9, j*15+10, j*15+11, j*15+12, j*15+13, j*15+14, j*15+15))
+			valueStrings = append(valueStrings, rowPlaceholders(j*columnsPerRow, columnsPerRow))
 			valueArgs = append(valueArgs,
 				loc.GeonameID, loc.Name, loc.ASCII_Name, loc.AlternateNames, loc.Latitude, loc.Longitude,
 				loc.FeatureClass, loc.FeatureCode, loc.CountryCode, loc.Admin1Code, loc.Admin2Code,
-				loc.Population, loc.Elevation, loc.TimeZone, loc.ModificationDate)
+				loc.Population, loc.Elevation, loc.TimeZone, loc.ModificationDate, importID)
 		}
 
 		// 构建完整的SQL语句
@@ -61,7 +121,7 @@ func (s *PostgresStorage) SaveLocations(locations []models.Location) error {
 		INSERT INTO locations (
 			geoname_id, name, ascii_name, alternate_names, latitude, longitude,
 			feature_class, feature_code, country_code, admin1_code, admin2_code,
-			population, elevation, timezone, modification_date
+			population, elevation, timezone, modification_date, import_id
 		) VALUES %s
 		ON CONFLICT (geoname_id) DO UPDATE SET
 			name = EXCLUDED.name,
@@ -77,13 +137,15 @@ func (s *PostgresStorage) SaveLocations(locations []models.Location) error {
 			population = EXCLUDED.population,
 			elevation = EXCLUDED.elevation,
 			timezone = EXCLUDED.timezone,
-			modification_date = EXCLUDED.modification_date
+			modification_date = EXCLUDED.modification_date,
+			import_id = EXCLUDED.import_id,
+			deleted_at = NULL
 		`, strings.Join(valueStrings, ","))
 
 		// 执行批量插入
-		_, err = tx.Exec(sql, valueArgs...)
+		_, err = tx.ExecContext(ctx, sql, valueArgs...)
 		if err != nil {
-			logger.Logger.Error("执行批量插入失败",
+			s.log.Error("执行批量插入失败",
 				zap.Int("batch_start", i),
 				zap.Int("batch_size", len(batch)),
 				zap.Error(err))
@@ -92,17 +154,205 @@ func (s *PostgresStorage) SaveLocations(locations []models.Location) error {
 
 		// 提交事务
 		if err := tx.Commit(); err != nil {
-			logger.Logger.Error("提交事务失败",
+			s.log.Error("提交事务失败",
 				zap.Int("batch_start", i),
 				zap.Int("batch_size", len(batch)),
 				zap.Error(err))
 			return fmt.Errorf("提交事务失败: %w", err)
 		}
 
-		logger.Logger.Info("成功处理一批数据",
+		s.progress.Add(int64(len(batch)))
+		s.log.Debug("成功处理一批数据",
 			zap.Int("batch_start", i),
 			zap.Int("batch_size", len(batch)))
 	}
 
 	return nil
 }
+
+// setImportID 在事务内设置当前导入ID，供 location_history 触发器记录变更来源
+func setImportID(ctx context.Context, tx *sql.Tx, importID int64) error {
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('geonames.import_id', $1, true)", strconv.FormatInt(importID, 10)); err != nil {
+		return fmt.Errorf("设置导入ID失败: %w", err)
+	}
+	return nil
+}
+
+// rowPlaceholders 生成一行的占位符，如 ($1, $2, $3)
+func rowPlaceholders(offset, n int) string {
+	placeholders := make([]string, n)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", offset+i+1)
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")"
+}
+
+// DeleteStaleLocations 软删除未在 importID 对应的导入中出现的记录。
+// 待删除数超过 maxDelete 时不做任何修改并返回 ErrTooManyStale；maxDelete 为负数表示不限制。
+func (s *PostgresStorage) DeleteStaleLocations(ctx context.Context, importID int64, maxDelete int) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setImportID(ctx, tx, importID); err != nil {
+		return 0, err
+	}
+
+	const staleCondition = "deleted_at IS NULL AND (import_id IS NULL OR import_id <> $1)"
+
+	var stale int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM locations WHERE "+staleCondition, importID).Scan(&stale); err != nil {
+		return 0, fmt.Errorf("统计过期记录失败: %w", err)
+	}
+	if maxDelete >= 0 && stale > int64(maxDelete) {
+		s.log.Error("过期记录数超过安全上限，已跳过删除",
+			zap.Int64("stale", stale),
+			zap.Int("max_delete", maxDelete))
+		return 0, fmt.Errorf("%w: %d > %d", storage.ErrTooManyStale, stale, maxDelete)
+	}
+
+	result, err := tx.ExecContext(ctx, "UPDATE locations SET deleted_at = NOW() WHERE "+staleCondition, importID)
+	if err != nil {
+		return 0, fmt.Errorf("删除过期记录失败: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("获取删除行数失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交事务失败: %w", err)
+	}
+
+	s.log.Info("已删除过期记录", zap.Int64("import_id", importID), zap.Int64("deleted", deleted))
+	return deleted, nil
+}
+
+// IterateLocations 按 geoname_id 升序遍历所有位置数据
+func (s *PostgresStorage) IterateLocations(ctx context.Context, fn func(models.Location) error) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT geoname_id, name, COALESCE(ascii_name, ''), COALESCE(alternate_names, ''),
+			latitude, longitude, COALESCE(feature_class::text, ''), COALESCE(feature_code, ''),
+			COALESCE(country_code::text, ''), COALESCE(admin1_code, ''), COALESCE(admin2_code, ''),
+			COALESCE(population, 0), COALESCE(elevation, 0), COALESCE(timezone, ''),
+			COALESCE(to_char(modification_date, 'YYYY-MM-DD'), '')
+		FROM locations
+		WHERE deleted_at IS NULL
+		ORDER BY geoname_id`)
+	if err != nil {
+		return fmt.Errorf("查询位置数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var loc models.Location
+		if err := rows.Scan(
+			&loc.GeonameID, &loc.Name, &loc.ASCII_Name, &loc.AlternateNames,
+			&loc.Latitude, &loc.Longitude, &loc.FeatureClass, &loc.FeatureCode,
+			&loc.CountryCode, &loc.Admin1Code, &loc.Admin2Code,
+			&loc.Population, &loc.Elevation, &loc.TimeZone, &loc.ModificationDate,
+		); err != nil {
+			return fmt.Errorf("读取位置数据失败: %w", err)
+		}
+		if err := fn(loc); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// locationColumns 查询接口返回的列
+const locationColumns = "geoname_id, name, ascii_name, latitude, longitude, country_code, population, feature_class, feature_code"
+
+// ListLocations 按 geoname_id 升序分页查询位置数据
+func (s *PostgresStorage) ListLocations(ctx context.Context, query storage.ListQuery) ([]models.Location, error) {
+	if query.CountryCode != "" {
+		return s.queryLocations(ctx, "SELECT "+locationColumns+" FROM locations WHERE country_code = $1 AND geoname_id > $2 AND deleted_at IS NULL ORDER BY geoname_id LIMIT $3",
+			query.CountryCode, query.AfterID, query.Limit)
+	}
+	return s.queryLocations(ctx, "SELECT "+locationColumns+" FROM locations WHERE geoname_id > $1 AND deleted_at IS NULL ORDER BY geoname_id LIMIT $2",
+		query.AfterID, query.Limit)
+}
+
+// CountLocations 统计位置数据条数，countryCode 为空时统计全部
+func (s *PostgresStorage) CountLocations(ctx context.Context, countryCode string) (int64, error) {
+	query, args := "SELECT COUNT(*) FROM locations WHERE deleted_at IS NULL", []interface{}{}
+	if countryCode != "" {
+		query, args = query+" AND country_code = $1", append(args, countryCode)
+	}
+
+	var count int64
+	if err := s.readDB().QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("统计位置数据失败: %w", err)
+	}
+	return count, nil
+}
+
+// queryLocations 执行查询并按 locationColumns 的顺序读取结果
+func (s *PostgresStorage) queryLocations(ctx context.Context, query string, args ...interface{}) ([]models.Location, error) {
+	rows, err := s.readDB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询位置数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []models.Location
+	for rows.Next() {
+		var loc models.Location
+		if err := scanLocation(rows, &loc); err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+
+	return locations, rows.Err()
+}
+
+// scanLocation 按 locationColumns 的顺序读取一行，extra 接收其后的附加列
+func scanLocation(rows *sql.Rows, loc *models.Location, extra ...interface{}) error {
+	dest := append([]interface{}{
+		&loc.GeonameID,
+		&loc.Name,
+		&loc.ASCII_Name,
+		&loc.Latitude,
+		&loc.Longitude,
+		&loc.CountryCode,
+		&loc.Population,
+		&loc.FeatureClass,
+		&loc.FeatureCode,
+	}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return fmt.Errorf("读取位置数据失败: %w", err)
+	}
+	return nil
+}
+
+// GetLocationHistory 按时间顺序返回地点的变更历史
+func (s *PostgresStorage) GetLocationHistory(ctx context.Context, geonameID int64) ([]models.LocationHistory, error) {
+	// geog 是由经纬度生成的列，其变化已体现在 latitude/longitude 中
+	rows, err := s.readDB().QueryContext(ctx, "SELECT import_id, operation, changes - 'geog', changed_at FROM location_history WHERE geoname_id = $1 ORDER BY changed_at, id", geonameID)
+	if err != nil {
+		return nil, fmt.Errorf("查询变更历史失败: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.LocationHistory
+	for rows.Next() {
+		var h models.LocationHistory
+		err := rows.Scan(
+			&h.ImportID,
+			&h.Operation,
+			&h.Changes,
+			&h.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("读取变更历史失败: %w", err)
+		}
+		history = append(history, h)
+	}
+
+	return history, rows.Err()
+}
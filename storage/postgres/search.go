@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/unxai/geonames-service/models"
+	"go.uber.org/zap"
+)
+
+// 搜索表达式须与迁移 005 中的索引表达式保持一致，否则无法命中索引
+const (
+	nameTrgmExpr       = "lower(geonames_unaccent(name))"
+	asciiNameTrgmExpr  = "lower(ascii_name)"
+	alternateTrgmExpr  = "lower(geonames_unaccent(alternate_names))"
+	searchDocumentExpr = "to_tsvector('simple', geonames_unaccent(name || ' ' || coalesce(alternate_names, '')))"
+)
+
+// SearchLocations 按名称搜索地点。
+// 精确模式使用全文索引匹配名称和别名中的完整单词；
+// 模糊模式使用三元组相似度，容忍拼写错误和变音符号差异（如 Muenchen、Munchen 均可匹配 München）；
+// 没有 pg_trgm 扩展时退化为 ILIKE 子串匹配。
+func (s *PostgresStorage) SearchLocations(ctx context.Context, query string, fuzzy bool, limit int) ([]models.LocationMatch, error) {
+	if fuzzy {
+		hasTrigram, err := s.hasTrigram(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !hasTrigram {
+			return s.searchSubstring(ctx, query, limit)
+		}
+
+		// 别名是逗号分隔的长字符串，整体相似度很低，使用 word_similarity 匹配其中最接近的部分
+		return s.querySearch(ctx, `
+			SELECT `+locationColumns+`,
+				GREATEST(
+					similarity(`+nameTrgmExpr+`, q),
+					similarity(`+asciiNameTrgmExpr+`, q),
+					word_similarity(q, `+alternateTrgmExpr+`)
+				) AS score
+			FROM locations, (SELECT lower(geonames_unaccent($1)) AS q) params
+			WHERE deleted_at IS NULL
+				AND (`+nameTrgmExpr+` % q OR `+asciiNameTrgmExpr+` % q OR q <% `+alternateTrgmExpr+`)
+			ORDER BY score DESC, population DESC NULLS LAST, geoname_id
+			LIMIT $2`,
+			query, limit)
+	}
+
+	return s.querySearch(ctx, `
+		SELECT `+locationColumns+`, ts_rank(`+searchDocumentExpr+`, q) AS score
+		FROM locations, plainto_tsquery('simple', geonames_unaccent($1)) q
+		WHERE deleted_at IS NULL AND `+searchDocumentExpr+` @@ q
+		ORDER BY score DESC, population DESC NULLS LAST, geoname_id
+		LIMIT $2`,
+		query, limit)
+}
+
+// likeEscaper 转义 LIKE 模式中的通配符，使查询词按字面匹配
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchSubstring 没有 pg_trgm 时的模糊搜索：名称、ASCII 名称或别名包含查询词即匹配，
+// 不容忍拼写错误。得分按名称完全相同、名称前缀、名称包含、仅别名包含依次降低
+func (s *PostgresStorage) searchSubstring(ctx context.Context, query string, limit int) ([]models.LocationMatch, error) {
+	return s.querySearch(ctx, `
+		SELECT `+locationColumns+`,
+			CASE
+				WHEN `+nameTrgmExpr+` = q OR `+asciiNameTrgmExpr+` = q THEN 1.0
+				WHEN `+nameTrgmExpr+` ILIKE p || '%' OR `+asciiNameTrgmExpr+` ILIKE p || '%' THEN 0.8
+				WHEN `+nameTrgmExpr+` ILIKE '%' || p || '%' OR `+asciiNameTrgmExpr+` ILIKE '%' || p || '%' THEN 0.6
+				ELSE 0.4
+			END AS score
+		FROM locations, (SELECT lower(geonames_unaccent($1)) AS q, lower(geonames_unaccent($2)) AS p) params
+		WHERE deleted_at IS NULL
+			AND (`+nameTrgmExpr+` ILIKE '%' || p || '%'
+				OR `+asciiNameTrgmExpr+` ILIKE '%' || p || '%'
+				OR `+alternateTrgmExpr+` ILIKE '%' || p || '%')
+		ORDER BY score DESC, population DESC NULLS LAST, geoname_id
+		LIMIT $3`,
+		query, likeEscaper.Replace(query), limit)
+}
+
+// hasTrigram 检测是否安装了 pg_trgm 扩展（迁移 005 在扩展可用时创建）
+func (s *PostgresStorage) hasTrigram(ctx context.Context) (bool, error) {
+	s.trigramMu.Lock()
+	defer s.trigramMu.Unlock()
+
+	if s.trigram != nil {
+		return *s.trigram, nil
+	}
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("检测pg_trgm失败: %w", err)
+	}
+
+	s.trigram = &exists
+	s.log.Info("模糊搜索模式", zap.Bool("pg_trgm", exists))
+	return exists, nil
+}
+
+// querySearch 执行名称搜索，结果列为 locationColumns 加匹配得分
+func (s *PostgresStorage) querySearch(ctx context.Context, query string, args ...interface{}) ([]models.LocationMatch, error) {
+	rows, err := s.readDB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("搜索地点失败: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []models.LocationMatch
+	for rows.Next() {
+		var loc models.LocationMatch
+		if err := scanLocation(rows, &loc.Location, &loc.Score); err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+
+	return locations, rows.Err()
+}
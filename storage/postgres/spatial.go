@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/storage"
+	"go.uber.org/zap"
+)
+
+// NearbyLocations 返回距离 (lat, lon) 不超过 radius 米的地点，按距离由近到远排序。
+// 存在 PostGIS geog 列时使用 ST_DWithin 和 KNN 索引排序，否则使用边界框预筛选加 haversine 公式。
+func (s *PostgresStorage) NearbyLocations(ctx context.Context, lat, lon, radius float64, limit int) ([]models.LocationDistance, error) {
+	hasGeography, err := s.hasGeography(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasGeography {
+		return s.queryNearby(ctx, `
+			SELECT `+locationColumns+`, ST_Distance(geog, point) AS distance_m
+			FROM locations, (SELECT ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography AS point) p
+			WHERE deleted_at IS NULL AND ST_DWithin(geog, point, $3)
+			ORDER BY geog <-> point
+			LIMIT $4`,
+			lat, lon, radius, limit)
+	}
+
+	minLat, maxLat, minLon, maxLon := storage.BoundingBox(lat, lon, radius)
+	return s.queryNearby(ctx, `
+		SELECT * FROM (
+			SELECT `+locationColumns+`,
+				$7 * 2 * ASIN(SQRT(
+					POWER(SIN(RADIANS(latitude - $1) / 2), 2) +
+					COS(RADIANS($1)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - $2) / 2), 2)
+				)) AS distance_m
+			FROM locations
+			WHERE deleted_at IS NULL
+				AND latitude BETWEEN $3 AND $4
+				AND longitude BETWEEN $5 AND $6
+		) nearby
+		WHERE distance_m <= $8
+		ORDER BY distance_m
+		LIMIT $9`,
+		lat, lon, minLat, maxLat, minLon, maxLon, storage.EarthRadiusMeters, radius, limit)
+}
+
+// queryNearby 执行空间查询，结果列为 locationColumns 加距离
+func (s *PostgresStorage) queryNearby(ctx context.Context, query string, args ...interface{}) ([]models.LocationDistance, error) {
+	rows, err := s.readDB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询附近地点失败: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []models.LocationDistance
+	for rows.Next() {
+		var loc models.LocationDistance
+		if err := scanLocation(rows, &loc.Location, &loc.Distance); err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+
+	return locations, rows.Err()
+}
+
+// hasGeography 检测 locations 表是否有 PostGIS geog 列（迁移 004 在 PostGIS 可用时添加）
+func (s *PostgresStorage) hasGeography(ctx context.Context) (bool, error) {
+	s.geographyMu.Lock()
+	defer s.geographyMu.Unlock()
+
+	if s.geography != nil {
+		return *s.geography, nil
+	}
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'locations' AND column_name = 'geog'
+		)`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("检测PostGIS失败: %w", err)
+	}
+
+	s.geography = &exists
+	s.log.Info("空间查询模式", zap.Bool("postgis", exists))
+	return exists, nil
+}
@@ -0,0 +1,199 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/unxai/geonames-service/models"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// 与 pg_trgm 的默认阈值一致：名称相似度 0.3，别名单词相似度 0.6
+	similarityThreshold     = 0.3
+	wordSimilarityThreshold = 0.6
+
+	// fuzzyCandidatesPerResult 模糊搜索时每条结果从三元组索引中取出的候选数。
+	// bm25 与三元组相似度的排序不一致，候选数须远大于结果数
+	fuzzyCandidatesPerResult = 50
+	minFuzzyCandidates       = 1000
+)
+
+// SearchLocations 按名称搜索地点。
+// 精确模式使用 FTS5 全文索引匹配名称和别名中的完整单词；
+// 模糊模式从 FTS5 三元组索引中取出候选，再按与 pg_trgm 相同的三元组相似度过滤和排序，
+// 容忍拼写错误和变音符号差异（如 Muenchen、Munchen 均可匹配 München）。
+func (s *SQLiteStorage) SearchLocations(ctx context.Context, query string, fuzzy bool, limit int) ([]models.LocationMatch, error) {
+	if fuzzy {
+		if match := trigramQuery(query); match != "" {
+			return s.fuzzySearch(ctx, query, match, limit)
+		}
+		// 查询过短，无法组成三元组，退化为按单词匹配
+	}
+
+	match := wordQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	// bm25 越小越相关，取相反数作为得分
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+locationColumns+`, m.score
+		FROM locations
+		JOIN (
+			SELECT rowid AS id, -bm25(locations_fts) AS score
+			FROM locations_fts
+			WHERE locations_fts MATCH $1
+		) m ON m.id = geoname_id
+		WHERE deleted_at IS NULL
+		ORDER BY m.score DESC, population DESC, geoname_id
+		LIMIT $2`,
+		match, limit)
+	if err != nil {
+		return nil, fmt.Errorf("搜索地点失败: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []models.LocationMatch
+	for rows.Next() {
+		var loc models.LocationMatch
+		if err := scanLocation(rows, &loc.Location, &loc.Score); err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+
+	return locations, rows.Err()
+}
+
+// fuzzySearch 按 bm25 取出与查询共享三元组较多的候选，计算相似度后过滤和排序。
+// bm25 偏向重复包含查询片段的长文本，相似度最高的地点不一定排在最前，因此候选数留有较大余量；
+// 排在候选上限之外的地点不会被返回
+func (s *SQLiteStorage) fuzzySearch(ctx context.Context, query, match string, limit int) ([]models.LocationMatch, error) {
+	candidates := max(limit*fuzzyCandidatesPerResult, minFuzzyCandidates)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+locationColumns+`, COALESCE(alternate_names, '')
+		FROM locations
+		WHERE geoname_id IN (
+			SELECT rowid FROM locations_trgm WHERE locations_trgm MATCH $1 ORDER BY rank LIMIT $2
+		) AND deleted_at IS NULL`,
+		match, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("搜索地点失败: %w", err)
+	}
+	defer rows.Close()
+
+	target := trigrams(query)
+	var locations []models.LocationMatch
+	for rows.Next() {
+		var loc models.LocationMatch
+		var alternateNames string
+		if err := scanLocation(rows, &loc.Location, &alternateNames); err != nil {
+			return nil, err
+		}
+
+		name := similarity(target, trigrams(loc.Name))
+		asciiName := similarity(target, trigrams(loc.ASCII_Name))
+		var alternate float64
+		for _, alias := range strings.Split(alternateNames, ",") {
+			alternate = max(alternate, similarity(target, trigrams(alias)))
+		}
+
+		if name < similarityThreshold && asciiName < similarityThreshold && alternate < wordSimilarityThreshold {
+			continue
+		}
+		loc.Score = max(name, asciiName, alternate)
+		locations = append(locations, loc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := locations[i], locations[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Population != b.Population {
+			return a.Population > b.Population
+		}
+		return a.GeonameID < b.GeonameID
+	})
+	if len(locations) > limit {
+		locations = locations[:limit]
+	}
+	return locations, nil
+}
+
+// unaccent 去除变音符号，与 FTS5 的 remove_diacritics 对应
+var unaccent = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// words 将文本转为小写、去除变音符号后按非字母数字字符拆分
+func words(text string) []string {
+	normalized, _, err := transform.String(unaccent, strings.ToLower(text))
+	if err != nil {
+		normalized = strings.ToLower(text)
+	}
+	return strings.FieldsFunc(normalized, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// wordQuery 构造 FTS5 查询：所有单词都必须出现，单词加引号以避免被解析为 FTS5 语法
+func wordQuery(text string) string {
+	var terms []string
+	for _, word := range words(text) {
+		terms = append(terms, `"`+word+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// trigramQuery 构造 FTS5 三元组查询：包含任一三元组即为匹配，bm25 对共享三元组多的行给出更高排名，
+// 但也偏向重复出现查询片段的文本，不等同于三元组相似度
+func trigramQuery(text string) string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, word := range words(text) {
+		r := []rune(word)
+		for i := 0; i+3 <= len(r); i++ {
+			gram := string(r[i : i+3])
+			if !seen[gram] {
+				seen[gram] = true
+				terms = append(terms, `"`+gram+`"`)
+			}
+		}
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// trigrams 按 pg_trgm 的规则提取三元组：每个单词前补两个空格、后补一个空格
+func trigrams(text string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range words(text) {
+		r := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// similarity 两个三元组集合的相似度：共有数 / 并集大小，与 pg_trgm 的 similarity 相同
+func similarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for gram := range a {
+		if _, ok := b[gram]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/storage"
+)
+
+// NearbyLocations 返回距离 (lat, lon) 不超过 radius 米的地点，按距离由近到远排序。
+// 先用 R*Tree 索引按经纬度范围筛选候选，再用 haversine 公式计算精确距离。
+func (s *SQLiteStorage) NearbyLocations(ctx context.Context, lat, lon, radius float64, limit int) ([]models.LocationDistance, error) {
+	minLat, maxLat, minLon, maxLon := storage.BoundingBox(lat, lon, radius)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT * FROM (
+			SELECT `+locationColumns+`,
+				$7 * 2 * asin(sqrt(
+					power(sin(radians(latitude - $1) / 2), 2) +
+					cos(radians($1)) * cos(radians(latitude)) * power(sin(radians(longitude - $2) / 2), 2)
+				)) AS distance_m
+			FROM locations
+			JOIN locations_rtree r ON r.id = geoname_id
+			WHERE r.max_lat >= $3 AND r.min_lat <= $4
+				AND r.max_lon >= $5 AND r.min_lon <= $6
+				AND deleted_at IS NULL
+		)
+		WHERE distance_m <= $8
+		ORDER BY distance_m
+		LIMIT $9`,
+		lat, lon, minLat, maxLat, minLon, maxLon, storage.EarthRadiusMeters, radius, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询附近地点失败: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []models.LocationDistance
+	for rows.Next() {
+		var loc models.LocationDistance
+		if err := scanLocation(rows, &loc.Location, &loc.Distance); err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+
+	return locations, rows.Err()
+}
@@ -0,0 +1,305 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/progress"
+	"github.com/unxai/geonames-service/storage"
+	"go.uber.org/zap"
+)
+
+// SQLiteStorage 实现了 Storage 接口的 SQLite 存储，用于无法运行 PostgreSQL 的离线部署
+type SQLiteStorage struct {
+	db        *sql.DB
+	batchSize int
+	log       *zap.Logger
+	progress  *progress.Bar
+}
+
+// NewSQLiteStorage 创建SQLite存储实例，batchSize 为每个事务写入的记录数
+func NewSQLiteStorage(db *sql.DB, batchSize int, log *zap.Logger) *SQLiteStorage {
+	return &SQLiteStorage{db: db, batchSize: batchSize, log: log}
+}
+
+// SetProgress 设置写入进度报告，传入 nil 关闭报告
+func (s *SQLiteStorage) SetProgress(bar *progress.Bar) {
+	s.progress = bar
+}
+
+// StartImport 登记一次新的导入，返回导入ID
+func (s *SQLiteStorage) StartImport(ctx context.Context, fullSync bool) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, "INSERT INTO imports (full_sync) VALUES ($1) RETURNING id", fullSync).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("登记导入失败: %w", err)
+	}
+	return id, nil
+}
+
+// FinishImport 记录导入完成时间和结果
+func (s *SQLiteStorage) FinishImport(ctx context.Context, importID int64, written, deleted int64) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE imports SET finished_at = CURRENT_TIMESTAMP, rows_written = $2, rows_deleted = $3 WHERE id = $1",
+		importID, written, deleted)
+	if err != nil {
+		return fmt.Errorf("更新导入记录失败: %w", err)
+	}
+	return nil
+}
+
+// upsertLocation 插入或更新一行。SQLite 单条语句的参数个数有限，逐行执行预编译语句
+const upsertLocation = `
+	INSERT INTO locations (
+		geoname_id, name, ascii_name, alternate_names, latitude, longitude,
+		feature_class, feature_code, country_code, admin1_code, admin2_code,
+		population, elevation, timezone, modification_date, import_id
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	ON CONFLICT (geoname_id) DO UPDATE SET
+		name = excluded.name,
+		ascii_name = excluded.ascii_name,
+		alternate_names = excluded.alternate_names,
+		latitude = excluded.latitude,
+		longitude = excluded.longitude,
+		feature_class = excluded.feature_class,
+		feature_code = excluded.feature_code,
+		country_code = excluded.country_code,
+		admin1_code = excluded.admin1_code,
+		admin2_code = excluded.admin2_code,
+		population = excluded.population,
+		elevation = excluded.elevation,
+		timezone = excluded.timezone,
+		modification_date = excluded.modification_date,
+		import_id = excluded.import_id,
+		deleted_at = NULL`
+
+// SaveLocations 批量保存位置数据，并将每行标记为在 importID 对应的导入中出现过
+func (s *SQLiteStorage) SaveLocations(ctx context.Context, importID int64, locations []models.Location) error {
+	for i := 0; i < len(locations); i += s.batchSize {
+		end := i + s.batchSize
+		if end > len(locations) {
+			end = len(locations)
+		}
+		batch := locations[i:end]
+
+		err := s.withImport(ctx, importID, func(tx *sql.Tx) error {
+			stmt, err := tx.PrepareContext(ctx, upsertLocation)
+			if err != nil {
+				return fmt.Errorf("预编译插入语句失败: %w", err)
+			}
+			defer stmt.Close()
+
+			for _, loc := range batch {
+				_, err := stmt.ExecContext(ctx,
+					loc.GeonameID, loc.Name, loc.ASCII_Name, loc.AlternateNames, loc.Latitude, loc.Longitude,
+					loc.FeatureClass, loc.FeatureCode, loc.CountryCode, loc.Admin1Code, loc.Admin2Code,
+					loc.Population, loc.Elevation, loc.TimeZone, loc.ModificationDate, importID)
+				if err != nil {
+					return fmt.Errorf("写入位置数据失败(geoname_id=%d): %w", loc.GeonameID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			s.log.Error("执行批量插入失败",
+				zap.Int("batch_start", i),
+				zap.Int("batch_size", len(batch)),
+				zap.Error(err))
+			return err
+		}
+
+		s.progress.Add(int64(len(batch)))
+		s.log.Debug("成功处理一批数据",
+			zap.Int("batch_start", i),
+			zap.Int("batch_size", len(batch)))
+	}
+
+	return nil
+}
+
+// withImport 在事务中执行 fn，事务内的写入由 location_history 触发器记录为 importID 对应的导入。
+// SQLite 没有会话变量，导入ID写入 import_context 表，提交前清空，效果等同于 PostgreSQL 的事务级 set_config。
+func (s *SQLiteStorage) withImport(ctx context.Context, importID int64, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO import_context (id, import_id) VALUES (1, $1)", importID); err != nil {
+		return fmt.Errorf("设置导入ID失败: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM import_context"); err != nil {
+		return fmt.Errorf("清除导入ID失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteStaleLocations 软删除未在 importID 对应的导入中出现的记录。
+// 待删除数超过 maxDelete 时不做任何修改并返回 ErrTooManyStale；maxDelete 为负数表示不限制。
+func (s *SQLiteStorage) DeleteStaleLocations(ctx context.Context, importID int64, maxDelete int) (int64, error) {
+	const staleCondition = "deleted_at IS NULL AND (import_id IS NULL OR import_id <> $1)"
+
+	var deleted int64
+	err := s.withImport(ctx, importID, func(tx *sql.Tx) error {
+		var stale int64
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM locations WHERE "+staleCondition, importID).Scan(&stale); err != nil {
+			return fmt.Errorf("统计过期记录失败: %w", err)
+		}
+		if maxDelete >= 0 && stale > int64(maxDelete) {
+			s.log.Error("过期记录数超过安全上限，已跳过删除",
+				zap.Int64("stale", stale),
+				zap.Int("max_delete", maxDelete))
+			return fmt.Errorf("%w: %d > %d", storage.ErrTooManyStale, stale, maxDelete)
+		}
+
+		result, err := tx.ExecContext(ctx, "UPDATE locations SET deleted_at = CURRENT_TIMESTAMP WHERE "+staleCondition, importID)
+		if err != nil {
+			return fmt.Errorf("删除过期记录失败: %w", err)
+		}
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("获取删除行数失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.log.Info("已删除过期记录", zap.Int64("import_id", importID), zap.Int64("deleted", deleted))
+	return deleted, nil
+}
+
+// IterateLocations 按 geoname_id 升序遍历所有位置数据
+func (s *SQLiteStorage) IterateLocations(ctx context.Context, fn func(models.Location) error) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT geoname_id, name, COALESCE(ascii_name, ''), COALESCE(alternate_names, ''),
+			latitude, longitude, COALESCE(feature_class, ''), COALESCE(feature_code, ''),
+			COALESCE(country_code, ''), COALESCE(admin1_code, ''), COALESCE(admin2_code, ''),
+			COALESCE(population, 0), COALESCE(elevation, 0), COALESCE(timezone, ''),
+			COALESCE(modification_date, '')
+		FROM locations
+		WHERE deleted_at IS NULL
+		ORDER BY geoname_id`)
+	if err != nil {
+		return fmt.Errorf("查询位置数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var loc models.Location
+		if err := rows.Scan(
+			&loc.GeonameID, &loc.Name, &loc.ASCII_Name, &loc.AlternateNames,
+			&loc.Latitude, &loc.Longitude, &loc.FeatureClass, &loc.FeatureCode,
+			&loc.CountryCode, &loc.Admin1Code, &loc.Admin2Code,
+			&loc.Population, &loc.Elevation, &loc.TimeZone, &loc.ModificationDate,
+		); err != nil {
+			return fmt.Errorf("读取位置数据失败: %w", err)
+		}
+		if err := fn(loc); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// locationColumns 查询接口返回的列
+const locationColumns = "geoname_id, name, COALESCE(ascii_name, ''), latitude, longitude, COALESCE(country_code, ''), " +
+	"COALESCE(population, 0), COALESCE(feature_class, ''), COALESCE(feature_code, '')"
+
+// ListLocations 按 geoname_id 升序分页查询位置数据
+func (s *SQLiteStorage) ListLocations(ctx context.Context, query storage.ListQuery) ([]models.Location, error) {
+	if query.CountryCode != "" {
+		return s.queryLocations(ctx, "SELECT "+locationColumns+" FROM locations WHERE country_code = $1 AND geoname_id > $2 AND deleted_at IS NULL ORDER BY geoname_id LIMIT $3",
+			query.CountryCode, query.AfterID, query.Limit)
+	}
+	return s.queryLocations(ctx, "SELECT "+locationColumns+" FROM locations WHERE geoname_id > $1 AND deleted_at IS NULL ORDER BY geoname_id LIMIT $2",
+		query.AfterID, query.Limit)
+}
+
+// CountLocations 统计位置数据条数，countryCode 为空时统计全部
+func (s *SQLiteStorage) CountLocations(ctx context.Context, countryCode string) (int64, error) {
+	query, args := "SELECT COUNT(*) FROM locations WHERE deleted_at IS NULL", []interface{}{}
+	if countryCode != "" {
+		query, args = query+" AND country_code = $1", append(args, countryCode)
+	}
+
+	var count int64
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("统计位置数据失败: %w", err)
+	}
+	return count, nil
+}
+
+// queryLocations 执行查询并按 locationColumns 的顺序读取结果
+func (s *SQLiteStorage) queryLocations(ctx context.Context, query string, args ...interface{}) ([]models.Location, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询位置数据失败: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []models.Location
+	for rows.Next() {
+		var loc models.Location
+		if err := scanLocation(rows, &loc); err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+
+	return locations, rows.Err()
+}
+
+// scanLocation 按 locationColumns 的顺序读取一行，extra 接收其后的附加列
+func scanLocation(rows *sql.Rows, loc *models.Location, extra ...interface{}) error {
+	dest := append([]interface{}{
+		&loc.GeonameID,
+		&loc.Name,
+		&loc.ASCII_Name,
+		&loc.Latitude,
+		&loc.Longitude,
+		&loc.CountryCode,
+		&loc.Population,
+		&loc.FeatureClass,
+		&loc.FeatureCode,
+	}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return fmt.Errorf("读取位置数据失败: %w", err)
+	}
+	return nil
+}
+
+// GetLocationHistory 按时间顺序返回地点的变更历史
+func (s *SQLiteStorage) GetLocationHistory(ctx context.Context, geonameID int64) ([]models.LocationHistory, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT import_id, operation, changes, changed_at FROM location_history WHERE geoname_id = $1 ORDER BY changed_at, id", geonameID)
+	if err != nil {
+		return nil, fmt.Errorf("查询变更历史失败: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.LocationHistory
+	for rows.Next() {
+		var h models.LocationHistory
+		var changes []byte
+		if err := rows.Scan(&h.ImportID, &h.Operation, &changes, &h.ChangedAt); err != nil {
+			return nil, fmt.Errorf("读取变更历史失败: %w", err)
+		}
+		h.Changes = changes
+		history = append(history, h)
+	}
+
+	return history, rows.Err()
+}
@@ -0,0 +1,235 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/unxai/geonames-service/config"
+	"github.com/unxai/geonames-service/db"
+	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/storage"
+	"go.uber.org/zap"
+)
+
+// newTestStorage 在临时目录中创建并迁移 SQLite 数据库
+func newTestStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.Path = filepath.Join(t.TempDir(), "geonames.db")
+
+	conn, err := db.Open(cfg)
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	log := zap.NewNop()
+	migrator, err := db.NewMigrator(conn, config.DriverSQLite, "", log)
+	if err != nil {
+		t.Fatalf("创建迁移器失败: %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+
+	return NewSQLiteStorage(conn, 100, log)
+}
+
+// importLocations 作为一次新的导入保存 locations，返回导入ID
+func importLocations(t *testing.T, s *SQLiteStorage, locations ...models.Location) int64 {
+	t.Helper()
+
+	ctx := context.Background()
+	importID, err := s.StartImport(ctx, true)
+	if err != nil {
+		t.Fatalf("登记导入失败: %v", err)
+	}
+	if err := s.SaveLocations(ctx, importID, locations); err != nil {
+		t.Fatalf("保存位置数据失败: %v", err)
+	}
+	return importID
+}
+
+func location(id int, name string, lat, lon float64) models.Location {
+	return models.Location{GeonameID: id, Name: name, ASCII_Name: name, Latitude: lat, Longitude: lon, CountryCode: "XX"}
+}
+
+func TestSaveLocationsUpsert(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	importLocations(t, s, location(1, "Berlin", 52.52, 13.40), location(2, "Hamburg", 53.55, 9.99))
+
+	updated := location(1, "Berlin", 52.52, 13.40)
+	updated.Population = 3644826
+	importLocations(t, s, updated)
+
+	count, err := s.CountLocations(ctx, "")
+	if err != nil {
+		t.Fatalf("统计位置数据失败: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("记录数 = %d，期望 2", count)
+	}
+
+	locations, err := s.ListLocations(ctx, storage.ListQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("查询位置数据失败: %v", err)
+	}
+	if locations[0].GeonameID != 1 || locations[0].Population != 3644826 {
+		t.Errorf("更新后的记录 = %+v", locations[0])
+	}
+
+	history, err := s.GetLocationHistory(ctx, 1)
+	if err != nil {
+		t.Fatalf("查询变更历史失败: %v", err)
+	}
+	if len(history) != 2 || history[0].Operation != "insert" || history[1].Operation != "update" {
+		t.Errorf("变更历史 = %+v，期望 insert 后 update", history)
+	}
+}
+
+func TestDeleteStaleLocations(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	importLocations(t, s, location(1, "Berlin", 52.52, 13.40), location(2, "Hamburg", 53.55, 9.99))
+	importID := importLocations(t, s, location(1, "Berlin", 52.52, 13.40))
+
+	if _, err := s.DeleteStaleLocations(ctx, importID, 0); !errors.Is(err, storage.ErrTooManyStale) {
+		t.Fatalf("超过安全上限时 err = %v，期望 ErrTooManyStale", err)
+	}
+
+	deleted, err := s.DeleteStaleLocations(ctx, importID, -1)
+	if err != nil {
+		t.Fatalf("删除过期记录失败: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("删除数 = %d，期望 1", deleted)
+	}
+
+	locations, err := s.ListLocations(ctx, storage.ListQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("查询位置数据失败: %v", err)
+	}
+	if len(locations) != 1 || locations[0].GeonameID != 1 {
+		t.Fatalf("软删除后的记录 = %+v，期望只剩 1", locations)
+	}
+
+	history, err := s.GetLocationHistory(ctx, 2)
+	if err != nil {
+		t.Fatalf("查询变更历史失败: %v", err)
+	}
+	if last := history[len(history)-1]; last.Operation != "delete" || last.ImportID == nil || *last.ImportID != importID {
+		t.Errorf("最后一条变更 = %s %v，期望由导入 %d 删除", last.Operation, last.ImportID, importID)
+	}
+
+	// 再次出现的记录恢复可见
+	importLocations(t, s, location(1, "Berlin", 52.52, 13.40), location(2, "Hamburg", 53.55, 9.99))
+	if count, _ := s.CountLocations(ctx, ""); count != 2 {
+		t.Errorf("恢复后记录数 = %d，期望 2", count)
+	}
+}
+
+func TestNearbyLocations(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	importLocations(t, s,
+		location(1, "Tiananmen", 39.9087, 116.3975),
+		location(2, "Beijing", 39.9042, 116.4074),
+		location(3, "Tianjin", 39.1422, 117.1767),
+		// 高纬度：经度差超过 radius/cos(lat) 但仍在半径以内
+		location(4, "Polar", 87.8, 60),
+	)
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		radius   float64
+		want     []int
+	}{
+		{"按距离排序", 39.9042, 116.4074, 10000, []int{2, 1}},
+		{"扩大半径", 39.9042, 116.4074, 150000, []int{2, 1, 3}},
+		{"高纬度", 85, 0, 500000, []int{4}},
+		{"半径以外", 85, 0, 400000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locations, err := s.NearbyLocations(ctx, tt.lat, tt.lon, tt.radius, 10)
+			if err != nil {
+				t.Fatalf("查询附近地点失败: %v", err)
+			}
+			var got []int
+			for _, loc := range locations {
+				if loc.Distance > tt.radius {
+					t.Errorf("%d 的距离 %.0f 超出半径", loc.GeonameID, loc.Distance)
+				}
+				got = append(got, loc.GeonameID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("结果 = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchLocations(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	munich := location(2867714, "München", 48.14, 11.58)
+	munich.ASCII_Name = "Munchen"
+	munich.AlternateNames = "Monaco di Baviera,Munich,Muenchen"
+	munich.Population = 1260391
+
+	locations := []models.Location{
+		munich,
+		location(1, "Münchenbernsdorf", 50.82, 11.93),
+		location(2, "Münster", 51.96, 7.63),
+	}
+	// 与查询共享大量三元组、bm25 排名更靠前，但相似度低于 München 的干扰项。
+	// 数量多于结果数的若干倍但少于候选上限，验证按 bm25 截取候选时不会漏掉相似度最高的地点
+	for i := 0; i < 300; i++ {
+		locations = append(locations, location(100+i, fmt.Sprintf("Xmuenchenx Ymuenchenx %d", i), 0, 0))
+	}
+	importLocations(t, s, locations...)
+
+	tests := []struct {
+		name  string
+		query string
+		fuzzy bool
+		first int
+	}{
+		{"精确匹配别名", "Munich", false, 2867714},
+		{"精确匹配忽略变音符号", "munchen", false, 2867714},
+		{"模糊匹配变音符号改写", "Muenchen", true, 2867714},
+		{"模糊匹配拼写错误", "Munchem", true, 2867714},
+		{"模糊匹配前缀更长的名称", "Muenster", true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := s.SearchLocations(ctx, tt.query, tt.fuzzy, 5)
+			if err != nil {
+				t.Fatalf("搜索地点失败: %v", err)
+			}
+			if len(matches) == 0 || matches[0].GeonameID != tt.first {
+				t.Fatalf("搜索 %q 的结果 = %+v，期望第一条为 %d", tt.query, matches, tt.first)
+			}
+			for i := 1; i < len(matches); i++ {
+				if matches[i].Score > matches[i-1].Score {
+					t.Errorf("结果未按得分降序排列: %+v", matches)
+				}
+			}
+		})
+	}
+
+	if matches, err := s.SearchLocations(ctx, "Atlantis", true, 5); err != nil || len(matches) != 0 {
+		t.Errorf("搜索不存在的名称返回 %+v, %v", matches, err)
+	}
+}
@@ -1,11 +1,51 @@
 package storage
 
 import (
+	"context"
+	"errors"
+
 	"github.com/unxai/geonames-service/models"
 )
 
-// Storage 定义了存储接口
+// ErrTooManyStale 待删除的过期记录数超过安全上限
+var ErrTooManyStale = errors.New("待删除的过期记录数超过安全上限")
+
+// ListQuery 列表查询条件
+type ListQuery struct {
+	CountryCode string // 国家代码，为空表示不限
+	AfterID     int64  // 游标：只返回 geoname_id 大于该值的记录
+	Limit       int    // 最多返回的条数
+}
+
+// Storage 定义了存储接口。ctx 用于取消查询和传递追踪信息
 type Storage interface {
-	// SaveLocations 批量保存位置数据
-	SaveLocations(locations []models.Location) error
+	// StartImport 登记一次新的导入，返回导入ID
+	StartImport(ctx context.Context, fullSync bool) (int64, error)
+
+	// SaveLocations 批量保存位置数据，并标记为在指定导入中出现过
+	SaveLocations(ctx context.Context, importID int64, locations []models.Location) error
+
+	// DeleteStaleLocations 软删除未在指定导入中出现的记录，返回删除的行数
+	DeleteStaleLocations(ctx context.Context, importID int64, maxDelete int) (int64, error)
+
+	// FinishImport 记录导入完成
+	FinishImport(ctx context.Context, importID int64, written, deleted int64) error
+
+	// IterateLocations 按 geoname_id 升序遍历所有位置数据
+	IterateLocations(ctx context.Context, fn func(models.Location) error) error
+
+	// ListLocations 按 geoname_id 升序分页查询位置数据
+	ListLocations(ctx context.Context, query ListQuery) ([]models.Location, error)
+
+	// CountLocations 统计位置数据条数，countryCode 为空时统计全部
+	CountLocations(ctx context.Context, countryCode string) (int64, error)
+
+	// NearbyLocations 返回距离 (lat, lon) 不超过 radius 米的地点，按距离由近到远排序
+	NearbyLocations(ctx context.Context, lat, lon, radius float64, limit int) ([]models.LocationDistance, error)
+
+	// SearchLocations 按名称搜索地点，fuzzy 为 true 时容忍拼写错误，结果按相关度和人口排序
+	SearchLocations(ctx context.Context, query string, fuzzy bool, limit int) ([]models.LocationMatch, error)
+
+	// GetLocationHistory 按时间顺序返回地点的变更历史
+	GetLocationHistory(ctx context.Context, geonameID int64) ([]models.LocationHistory, error)
 }
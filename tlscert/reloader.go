@@ -0,0 +1,131 @@
+// Package tlscert 加载 HTTPS 服务的证书，并在证书或私钥文件变化时自动重新加载，
+// 更换证书（如 cert-manager 续期）无需重启服务。
+package tlscert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadDelay 文件变化后等待的时间。证书和私钥通常先后写入，合并短时间内的多次变化只加载一次
+const reloadDelay = 500 * time.Millisecond
+
+// Reloader 持有当前使用的证书，通过 GetCertificate 提供给 tls.Config
+type Reloader struct {
+	certFile string
+	keyFile  string
+	log      *zap.Logger
+	cert     atomic.Pointer[tls.Certificate]
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+}
+
+// NewReloader 加载证书并开始监听文件变化，证书无法加载时返回错误。使用完毕后调用 Close
+func NewReloader(certFile, keyFile string, log *zap.Logger) (*Reloader, error) {
+	r := &Reloader{
+		certFile: filepath.Clean(certFile),
+		keyFile:  filepath.Clean(keyFile),
+		log:      log,
+		done:     make(chan struct{}),
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("监听证书文件失败: %w", err)
+	}
+	// 监听所在目录而不是文件本身：替换文件（重命名、Kubernetes Secret 的符号链接切换）后对原文件的监听会失效
+	for _, dir := range uniqueDirs(r.certFile, r.keyFile) {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("监听证书目录 %s 失败: %w", dir, err)
+		}
+	}
+	r.watcher = watcher
+
+	go r.watch()
+	return r, nil
+}
+
+// GetCertificate 返回当前的证书，用作 tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Close 停止监听文件变化
+func (r *Reloader) Close() error {
+	err := r.watcher.Close()
+	<-r.done
+	return err
+}
+
+// load 读取证书和私钥，成功后替换当前证书
+func (r *Reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载TLS证书失败: %w", err)
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+// watch 在证书或私钥变化后重新加载。新文件无法加载时（如证书已更新而私钥尚未写入）继续使用旧证书，
+// 等待下一次变化
+func (r *Reloader) watch() {
+	defer close(r.done)
+
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if r.relevant(event.Name) {
+				timer.Reset(reloadDelay)
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.log.Warn("监听证书文件出错", zap.Error(err))
+		case <-timer.C:
+			if err := r.load(); err != nil {
+				r.log.Warn("重新加载TLS证书失败，继续使用当前证书", zap.Error(err))
+				continue
+			}
+			r.log.Info("已重新加载TLS证书", zap.String("cert_file", r.certFile))
+		}
+	}
+}
+
+// relevant 是否为证书、私钥或 Kubernetes 挂载目录中以 .. 开头的数据链接
+func (r *Reloader) relevant(name string) bool {
+	name = filepath.Clean(name)
+	return name == r.certFile || name == r.keyFile || strings.HasPrefix(filepath.Base(name), "..")
+}
+
+func uniqueDirs(files ...string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
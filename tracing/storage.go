@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedStorage 为每次调用创建 storage.<方法名> span 的 storage.Storage 装饰器
+type tracedStorage struct {
+	next storage.Storage
+}
+
+// Storage 为 next 的每个方法记录 span，span 的父级为调用方 ctx 中的 span
+func Storage(next storage.Storage) storage.Storage {
+	return &tracedStorage{next: next}
+}
+
+func (s *tracedStorage) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Start(ctx, "storage."+method, attrs...)
+}
+
+// end 在方法返回时调用，err 指向方法的命名返回值
+func end(span trace.Span, err *error) {
+	End(span, *err)
+}
+
+func (s *tracedStorage) StartImport(ctx context.Context, fullSync bool) (id int64, err error) {
+	ctx, span := s.start(ctx, "StartImport", attribute.Bool("import.full_sync", fullSync))
+	defer end(span, &err)
+	id, err = s.next.StartImport(ctx, fullSync)
+	span.SetAttributes(attribute.Int64("import.id", id))
+	return id, err
+}
+
+func (s *tracedStorage) SaveLocations(ctx context.Context, importID int64, locations []models.Location) (err error) {
+	ctx, span := s.start(ctx, "SaveLocations", attribute.Int64("import.id", importID), attribute.Int("locations.count", len(locations)))
+	defer end(span, &err)
+	return s.next.SaveLocations(ctx, importID, locations)
+}
+
+func (s *tracedStorage) DeleteStaleLocations(ctx context.Context, importID int64, maxDelete int) (n int64, err error) {
+	ctx, span := s.start(ctx, "DeleteStaleLocations", attribute.Int64("import.id", importID), attribute.Int("import.max_delete", maxDelete))
+	defer end(span, &err)
+	n, err = s.next.DeleteStaleLocations(ctx, importID, maxDelete)
+	span.SetAttributes(attribute.Int64("locations.deleted", n))
+	return n, err
+}
+
+func (s *tracedStorage) FinishImport(ctx context.Context, importID int64, written, deleted int64) (err error) {
+	ctx, span := s.start(ctx, "FinishImport", attribute.Int64("import.id", importID))
+	defer end(span, &err)
+	return s.next.FinishImport(ctx, importID, written, deleted)
+}
+
+func (s *tracedStorage) IterateLocations(ctx context.Context, fn func(models.Location) error) (err error) {
+	ctx, span := s.start(ctx, "IterateLocations")
+	defer end(span, &err)
+	return s.next.IterateLocations(ctx, fn)
+}
+
+func (s *tracedStorage) ListLocations(ctx context.Context, query storage.ListQuery) (locations []models.Location, err error) {
+	ctx, span := s.start(ctx, "ListLocations",
+		attribute.String("query.country_code", query.CountryCode),
+		attribute.Int64("query.after_id", query.AfterID),
+		attribute.Int("query.limit", query.Limit))
+	defer end(span, &err)
+	locations, err = s.next.ListLocations(ctx, query)
+	span.SetAttributes(attribute.Int("locations.count", len(locations)))
+	return locations, err
+}
+
+func (s *tracedStorage) CountLocations(ctx context.Context, countryCode string) (n int64, err error) {
+	ctx, span := s.start(ctx, "CountLocations", attribute.String("query.country_code", countryCode))
+	defer end(span, &err)
+	return s.next.CountLocations(ctx, countryCode)
+}
+
+func (s *tracedStorage) NearbyLocations(ctx context.Context, lat, lon, radius float64, limit int) (locations []models.LocationDistance, err error) {
+	ctx, span := s.start(ctx, "NearbyLocations",
+		attribute.Float64("query.radius", radius),
+		attribute.Int("query.limit", limit))
+	defer end(span, &err)
+	locations, err = s.next.NearbyLocations(ctx, lat, lon, radius, limit)
+	span.SetAttributes(attribute.Int("locations.count", len(locations)))
+	return locations, err
+}
+
+func (s *tracedStorage) SearchLocations(ctx context.Context, query string, fuzzy bool, limit int) (locations []models.LocationMatch, err error) {
+	ctx, span := s.start(ctx, "SearchLocations",
+		attribute.Bool("query.fuzzy", fuzzy),
+		attribute.Int("query.limit", limit))
+	defer end(span, &err)
+	locations, err = s.next.SearchLocations(ctx, query, fuzzy, limit)
+	span.SetAttributes(attribute.Int("locations.count", len(locations)))
+	return locations, err
+}
+
+func (s *tracedStorage) GetLocationHistory(ctx context.Context, geonameID int64) (history []models.LocationHistory, err error) {
+	ctx, span := s.start(ctx, "GetLocationHistory", attribute.Int64("query.geoname_id", geonameID))
+	defer end(span, &err)
+	return s.next.GetLocationHistory(ctx, geonameID)
+}
@@ -0,0 +1,115 @@
+// Package tracing 基于 OpenTelemetry 的链路追踪：HTTP 请求、存储查询和导入的各个阶段记录为 span，
+// 通过 W3C Trace Context 请求头传播，日志通过 trace_id 与 span 关联。
+// 不使用 otel 的全局 TracerProvider 和传播器，与应用其他依赖一样按实例传递。
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/unxai/geonames-service/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+)
+
+// instrumentationName 本服务创建的 span 所属的 tracer 名称
+const instrumentationName = "github.com/unxai/geonames-service"
+
+// Propagator W3C Trace Context 和 Baggage 传播器
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Provider 应用实例的 TracerProvider。不设置为全局，同一进程中的多个实例各自导出；
+// 子 span 通过 Start 沿用 ctx 中父 span 所属的 Provider
+type Provider struct {
+	tp       trace.TracerProvider
+	shutdown func(context.Context) error
+}
+
+// New 按配置创建导出器。exporter 为 none 时不记录 span，
+// 但上游传入的 trace_id 仍会写入日志并传递下去
+func New(cfg *config.Config) (*Provider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Tracing.Exporter {
+	case config.TracingOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Tracing.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Tracing.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case config.TracingStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return &Provider{tp: noop.NewTracerProvider(), shutdown: func(context.Context) error { return nil }}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("创建追踪导出器失败: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", cfg.Tracing.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("创建追踪资源失败: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+	)
+	return &Provider{tp: tp, shutdown: tp.Shutdown}, nil
+}
+
+// TracerProvider 返回底层的 TracerProvider，供 otelmux 等第三方中间件使用
+func (p *Provider) TracerProvider() trace.TracerProvider {
+	return p.tp
+}
+
+// Start 使用本实例的 Provider 创建 span，用于没有上游的根 span（如一次导入）
+func (p *Provider) Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return p.tp.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Shutdown 导出缓冲中的 span 并关闭导出器
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if err := p.shutdown(ctx); err != nil {
+		return fmt.Errorf("关闭追踪导出器失败: %w", err)
+	}
+	return nil
+}
+
+// Start 创建 ctx 中 span 的子 span，使用父 span 所属的 Provider；ctx 中没有 span 时不记录。
+// 调用方须调用 End 结束
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(instrumentationName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End 结束 span，err 不为 nil 时记录错误并将状态设为 Error
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// LogFields 返回 ctx 中 span 的 trace_id 和 span_id 日志字段，没有 span 时返回 nil
+func LogFields(ctx context.Context) []zap.Field {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+	}
+}
@@ -4,22 +4,24 @@ import (
 	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
-	"github.com/unxai/geonames-service/config"
-	"github.com/unxai/geonames-service/logger"
 	"github.com/unxai/geonames-service/models"
+	"github.com/unxai/geonames-service/progress"
+	"github.com/unxai/geonames-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
-const workerCount = 10 // 并发worker数量
-
 // parseLocation 解析单行数据为Location结构
 func parseLocation(line string) (models.Location, error) {
 	fields := strings.Split(line, "\t")
@@ -52,119 +54,175 @@ func parseLocation(line string) (models.Location, error) {
 	}, nil
 }
 
-// DownloadGeoData 修改为返回数据而不是保存文件
-func DownloadGeoData() ([]models.Location, error) {
-	// 获取配置
-	cfg, err := config.LoadConfig()
+// Downloader 下载并解析GeoNames数据
+type Downloader struct {
+	url     string
+	workers int
+	log     *zap.Logger
+	stats   ParseStats
+}
+
+// ParseStats 最近一次解析的统计
+type ParseStats struct {
+	Parsed     int // 解析成功的行数
+	Rejected   int // 格式错误被丢弃的行数
+	Duplicates int // 重复的 geoname_id 数量
+}
+
+// Stats 返回最近一次 DownloadGeoData 的解析统计
+func (d *Downloader) Stats() ParseStats {
+	return d.stats
+}
+
+// NewDownloader 创建下载器，workers 为并发解析的worker数量
+func NewDownloader(url string, workers int, log *zap.Logger) *Downloader {
+	return &Downloader{url: url, workers: workers, log: log}
+}
+
+// DownloadGeoData 下载（或读取本地缓存）并解析数据，下载和解析分别记录为 ctx 中 span 的子 span
+func (d *Downloader) DownloadGeoData(ctx context.Context) ([]models.Location, error) {
+	data, err := d.fetch(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("加载配置失败: %w", err)
+		return nil, err
 	}
+	return d.parseZipData(ctx, data)
+}
+
+// fetch 返回zip文件内容，优先使用本地缓存
+func (d *Downloader) fetch(ctx context.Context) (data []byte, err error) {
+	ctx, span := tracing.Start(ctx, "import.download", attribute.String("download.url", d.url))
+	defer func() {
+		span.SetAttributes(attribute.Int("download.bytes", len(data)))
+		tracing.End(span, err)
+	}()
 
 	// 检查本地缓存
 	cacheFile := "data/allCountries.zip"
-	if _, err = os.Stat(cacheFile); err == nil {
+	if _, err := os.Stat(cacheFile); err == nil {
 		// 如果缓存文件存在，直接使用缓存文件
-		logger.Logger.Info("使用本地缓存文件")
-		var data []byte
-		data, err = os.ReadFile(cacheFile)
+		d.log.Info("使用本地缓存文件")
+		span.SetAttributes(attribute.Bool("download.cached", true))
+		data, err := os.ReadFile(cacheFile)
 		if err != nil {
 			return nil, fmt.Errorf("读取缓存文件失败: %w", err)
 		}
-		return parseZipData(data)
+		return data, nil
 	}
 
 	// 创建缓存目录
-	if err = os.MkdirAll("data", 0755); err != nil {
+	if err := os.MkdirAll("data", 0755); err != nil {
 		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
 	}
 
-	logger.Logger.Info("开始下载数据文件")
+	d.log.Info("开始下载数据文件")
 	// 发起 HTTP 请求获取数据
-	resp, err := http.Get(cfg.Download.URL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建下载请求失败: %w", err)
+	}
+	// 下载地址是外部服务，不注入 traceparent 和 baggage，避免泄露内部链路信息；下载耗时由 import.download span 记录
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("下载数据文件失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 读取响应内容
-	body, err := io.ReadAll(resp.Body)
+	// 错误页面不能当作数据写入缓存，否则之后每次都会读到损坏的缓存
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载数据文件失败: HTTP %s", resp.Status)
+	}
+
+	// 读取响应内容，同时报告下载进度
+	total := resp.ContentLength
+	if total < 0 {
+		total = 0
+	}
+	bar := progress.Start(d.log, "下载", progress.UnitBytes, total)
+	body, err := io.ReadAll(bar.Reader(resp.Body))
+	bar.Finish()
 	if err != nil {
 		return nil, fmt.Errorf("读取响应内容失败: %w", err)
 	}
 
 	// 保存到缓存文件
 	if err := os.WriteFile(cacheFile, body, 0644); err != nil {
-		logger.Logger.Warn("保存缓存文件失败", zap.Error(err))
+		d.log.Warn("保存缓存文件失败", zap.Error(err))
 	}
 
-	return parseZipData(body)
-
+	return body, nil
 }
 
-// parseZipData 解析zip数据
-func parseZipData(data []byte) ([]models.Location, error) {
+// parseZipData 并发解析zip数据
+func (d *Downloader) parseZipData(ctx context.Context, data []byte) (locations []models.Location, err error) {
+	_, span := tracing.Start(ctx, "import.parse")
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("parse.parsed", d.stats.Parsed),
+			attribute.Int("parse.rejected", d.stats.Rejected),
+			attribute.Int("parse.duplicates", d.stats.Duplicates))
+		tracing.End(span, err)
+	}()
+
 	// 从内存中读取 zip 文件
 	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
 	if err != nil {
 		return nil, fmt.Errorf("解析zip文件失败: %w", err)
 	}
 
-	var locations []models.Location
+	// 查找数据文件
+	var dataFile *zip.File
+	for _, file := range zipReader.File {
+		if file.Name == "allCountries.txt" {
+			dataFile = file
+			break
+		}
+	}
+	if dataFile == nil {
+		return nil, fmt.Errorf("zip文件中未找到allCountries.txt")
+	}
+
+	rc, err := dataFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("打开zip文件失败: %w", err)
+	}
+	defer rc.Close()
+
+	// 解析进度按已读取的解压字节数计算，同时统计解析的行数
+	bar := progress.Start(d.log, "解析", progress.UnitBytes, int64(dataFile.UncompressedSize64), progress.WithItems("行"))
+	defer bar.Finish()
+
+	var results []parsedLocation
+	var rejected atomic.Int64
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
 	// 使用更大的缓冲区来避免阻塞
-	tasks := make(chan string, 10000)
-
-	// 创建计数器用于跟踪进度
-	var processedCount int32
+	tasks := make(chan parsedLine, 10000)
 
 	// 启动worker
-	for i := 0; i < workerCount; i++ {
+	for i := 0; i < d.workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for line := range tasks {
-				location, err := parseLocation(line)
+			for task := range tasks {
+				location, err := parseLocation(task.line)
 				if err != nil {
-					logger.Logger.Warn("解析数据行失败", zap.Error(err))
+					d.log.Warn("解析数据行失败", zap.Int("line", task.index+1), zap.Error(err))
+					rejected.Add(1)
 					continue
 				}
-
 				mu.Lock()
-				locations = append(locations, location)
-				processedCount++
-				if processedCount%1000 == 0 {
-					logger.Logger.Info("处理进度", zap.Int32("processed", processedCount))
-				}
+				results = append(results, parsedLocation{index: task.index, location: location})
 				mu.Unlock()
+				bar.AddItems(1)
 			}
 		}()
 	}
 
-	// 解析 zip 文件中的数据
-	for _, file := range zipReader.File {
-		if file.Name == "allCountries.txt" {
-			rc, err := file.Open()
-			if err != nil {
-				return nil, fmt.Errorf("打开zip文件失败: %w", err)
-			}
-
-			// 使用scanner一次性处理数据
-			scanner := bufio.NewScanner(rc)
-			for scanner.Scan() {
-				tasks <- scanner.Text()
-			}
-
-			// 检查scanner是否有错误
-			if err := scanner.Err(); err != nil {
-				rc.Close()
-				return nil, fmt.Errorf("读取文件失败: %w", err)
-			}
-
-			rc.Close()
-			break
-		}
+	// 使用scanner一次性处理数据
+	scanner := bufio.NewScanner(bar.Reader(rc))
+	for index := 0; scanner.Scan(); index++ {
+		tasks <- parsedLine{index: index, line: scanner.Text()}
 	}
 
 	// 关闭任务channel
@@ -173,8 +231,65 @@ func parseZipData(data []byte) ([]models.Location, error) {
 	// 等待所有worker完成
 	wg.Wait()
 
-	logger.Logger.Info("数据解析完成",
+	// 检查scanner是否有错误
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	// worker 完成顺序不确定，按原始行号恢复输入顺序
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].index < results[j].index
+	})
+
+	locations = make([]models.Location, len(results))
+	for i, r := range results {
+		locations[i] = r.location
+	}
+	locations = d.dedupLocations(locations)
+	d.stats = ParseStats{
+		Parsed:     len(results),
+		Rejected:   int(rejected.Load()),
+		Duplicates: len(results) - len(locations),
+	}
+
+	d.log.Info("数据解析完成",
 		zap.Int("total_locations", len(locations)))
 
 	return locations, nil
 }
+
+// parsedLine 携带原始行号的解析任务
+type parsedLine struct {
+	index int
+	line  string
+}
+
+// parsedLocation 解析结果及其原始行号，用于恢复输入顺序。不保留原始行，避免排序期间多占用一份文本
+type parsedLocation struct {
+	index    int
+	location models.Location
+}
+
+// dedupLocations 按 geoname_id 去重，保留首次出现的位置、使用最后一次出现的数据，
+// 避免同一批次中重复的 ID 导致 ON CONFLICT 更新同一行两次
+func (d *Downloader) dedupLocations(locations []models.Location) []models.Location {
+	positions := make(map[int]int, len(locations))
+	result := locations[:0]
+	duplicates := 0
+
+	for _, loc := range locations {
+		if pos, ok := positions[loc.GeonameID]; ok {
+			result[pos] = loc
+			duplicates++
+			continue
+		}
+		positions[loc.GeonameID] = len(result)
+		result = append(result, loc)
+	}
+
+	if duplicates > 0 {
+		d.log.Warn("发现重复的geoname_id，已去重", zap.Int("duplicates", duplicates))
+	}
+
+	return result
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// geonamesLine 构造一行 allCountries.txt 格式的数据
+func geonamesLine(id int, name string) string {
+	fields := make([]string, 19)
+	fields[0] = fmt.Sprint(id)
+	fields[1] = name
+	fields[2] = name
+	fields[4] = "1.5"
+	fields[5] = "2.5"
+	fields[8] = "XX"
+	fields[14] = "100"
+	return strings.Join(fields, "\t")
+}
+
+// zipData 将 lines 打包为只包含 allCountries.txt 的 zip 文件
+func zipData(t *testing.T, lines []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("allCountries.txt")
+	if err != nil {
+		t.Fatalf("创建zip条目失败: %v", err)
+	}
+	if _, err := f.Write([]byte(strings.Join(lines, "\n") + "\n")); err != nil {
+		t.Fatalf("写入zip条目失败: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("关闭zip失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestParseZipDataOrderAndDuplicates 多个 worker 并发解析时，输出保持输入顺序；
+// 重复的 geoname_id 保留首次出现的位置，使用最后一次出现的数据
+func TestParseZipDataOrderAndDuplicates(t *testing.T) {
+	const total = 5000
+	// 单核机器上同样让 worker 并行执行，完成顺序才会与输入顺序不同
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	var lines []string
+	for id := 1; id <= total; id++ {
+		lines = append(lines, geonamesLine(id, fmt.Sprintf("place-%d", id)))
+	}
+	// 重复出现的 ID 及格式错误的行
+	lines = append(lines, geonamesLine(10, "place-10-v2"), "broken line", geonamesLine(10, "place-10-v3"), geonamesLine(4000, "place-4000-v2"))
+	data := zipData(t, lines)
+
+	for run := 0; run < 5; run++ {
+		d := NewDownloader("", 8, zap.NewNop())
+		locations, err := d.parseZipData(context.Background(), data)
+		if err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+
+		if len(locations) != total {
+			t.Fatalf("结果数 = %d，期望 %d", len(locations), total)
+		}
+		for i, loc := range locations {
+			if loc.GeonameID != i+1 {
+				t.Fatalf("第 %d 条的 geoname_id = %d，期望 %d（输出未保持输入顺序）", i, loc.GeonameID, i+1)
+			}
+		}
+		if name := locations[9].Name; name != "place-10-v3" {
+			t.Errorf("重复 ID 10 的名称 = %s，期望最后一次出现的 place-10-v3", name)
+		}
+		if name := locations[3999].Name; name != "place-4000-v2" {
+			t.Errorf("重复 ID 4000 的名称 = %s，期望最后一次出现的 place-4000-v2", name)
+		}
+
+		want := ParseStats{Parsed: total + 3, Rejected: 1, Duplicates: 3}
+		if stats := d.Stats(); stats != want {
+			t.Errorf("解析统计 = %+v，期望 %+v", stats, want)
+		}
+	}
+}